@@ -0,0 +1,102 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+// Sink receives the messages that can't be published to the output.
+type Sink interface {
+	Send(m *message.Message, reason string) error
+	Close() error
+}
+
+type Config struct {
+	NsqdTCPAddress string `config:"nsqd_tcp_address" json:"nsqd_tcp_address"`
+	Topic          string `config:"topic" json:"topic"`
+}
+
+// envelope is published to the dead letter topic, keeping the original body
+// as is when it is json.
+type envelope struct {
+	Topic     string      `json:"topic"`
+	ID        string      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Attempts  uint16      `json:"attempts"`
+	Reason    string      `json:"reason"`
+	Body      interface{} `json:"body"`
+}
+
+// NsqSink publishes dead letters to a nsqd topic, wrapped in an envelope
+// recording where they come from and why.
+type NsqSink struct {
+	config   *Config
+	topic    *outputs.Template
+	producer *nsq.Producer
+}
+
+func NewNsqSink(config *Config) (*NsqSink, error) {
+	topic, err := outputs.NewTemplate("topic", config.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("parse dead letter topic template fail: %w", err)
+	}
+
+	log.Infof("connect dead letter: %s", config.NsqdTCPAddress)
+	nsqConfig := nsq.NewConfig()
+	nsqConfig.UserAgent = fmt.Sprintf("nsq-tool-kit/%s go-nsq/%s", "0.0.1", nsq.VERSION)
+	producer, err := nsq.NewProducer(config.NsqdTCPAddress, nsqConfig)
+	if err != nil {
+		return nil, err
+	}
+	producer.SetLogger(log.StdInfoLogger(), nsq.LogLevelInfo)
+	if err := producer.Ping(); err != nil {
+		producer.Stop()
+		return nil, err
+	}
+
+	return &NsqSink{
+		config:   config,
+		topic:    topic,
+		producer: producer,
+	}, nil
+}
+
+func (s *NsqSink) Send(m *message.Message, reason string) error {
+	topic, err := s.topic.Render(m)
+	if err != nil {
+		return err
+	}
+	if !nsq.IsValidTopicName(topic) {
+		return fmt.Errorf("invalid dead letter topic %q", topic)
+	}
+
+	data := m.GetData()
+	e := envelope{
+		Topic:     m.GetTopic(),
+		ID:        string(data.ID[:]),
+		Timestamp: time.Unix(0, data.Timestamp),
+		Attempts:  data.Attempts,
+		Reason:    reason,
+		Body:      string(data.Body),
+	}
+	if json.Valid(data.Body) {
+		e.Body = json.RawMessage(data.Body)
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.producer.Publish(topic, body)
+}
+
+func (s *NsqSink) Close() error {
+	s.producer.Stop()
+	return nil
+}
@@ -7,14 +7,31 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/marmotedu/errors"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
 	"github.com/nsqio/go-nsq"
 
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/config"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/deadletter"
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/metrics"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/clickhouse"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/console"
 	es "github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/elasticsearch"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/file"
+	webhook "github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/http"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/kafka"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/loki"
+	nsqout "github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/nsq"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/redis"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/s3"
+	sqlout "github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/sql"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/processor"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/retry"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/spool"
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/store"
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/store/etcd"
 	genericoptions "github.com/JieTrancender/nsq-tool-kit/internal/pkg/options"
@@ -24,14 +41,25 @@ type manager struct {
 	gs  *shutdown.GracefulShutdown
 	cfg *config.Config
 
-	esConfig *es.Config
-	esClient *es.Client
+	output     outputs.Client
+	outputDone chan struct{}
+	deadLetter deadletter.Sink
+	requeuer   *retry.Requeuer
 
 	nsqConfig *nsq.Config
 
 	topics map[string]*Consumer
 
-	msgChan chan *message.Message
+	queue   *queue
+	done    chan struct{}
+	toucher *toucher
+
+	parsers    map[string]*processor.Parser
+	dedupStore processor.DedupStore
+	schemas    *processor.Schemas
+	scripts    *processor.Scripts
+	geoIP      []*processor.GeoIPDatabase
+	userAgent  *processor.UserAgentParser
 
 	storeIns store.Factory
 }
@@ -42,49 +70,474 @@ func createConsumerManager(cfg *config.Config) (*manager, error) {
 
 	nsqConfig := nsq.NewConfig()
 	nsqConfig.UserAgent = fmt.Sprintf("nsq-tool-kit/%s go-nsq/%s", "0.0.1", nsq.VERSION)
+	// Attempts are checked by the retry policy, which dead letters messages
+	// instead of go-nsq silently finishing them.
+	nsqConfig.MaxAttempts = 0
 	return &manager{
-		gs:  gs,
-		cfg: cfg,
-		esConfig: &es.Config{
-			Addrs:    cfg.Elasticsearch.Addrs,
-			Username: cfg.Elasticsearch.Username,
-			Password: cfg.Elasticsearch.Password,
-		},
+		gs:        gs,
+		cfg:       cfg,
 		nsqConfig: nsqConfig,
 		topics:    make(map[string]*Consumer),
+		queue:     newQueue(cfg.Output.QueueSize),
 	}, nil
 }
 
-func (m *manager) initialize() error {
-	client, err := es.NewClient(m.esConfig)
+func esTopicConfig(o *genericoptions.ElasticsearchTopicOptions) *es.TopicConfig {
+	return &es.TopicConfig{
+		Cluster:      o.Cluster,
+		Index:        o.Index,
+		DataStream:   o.DataStream,
+		Pipeline:     o.Pipeline,
+		RoutingField: o.RoutingField,
+		DocumentID:   o.DocumentID,
+		IDFields:     o.IDFields,
+		OpType:       o.OpType,
+	}
+}
+
+func retryPolicy(o *genericoptions.RetryTopicOptions) *retry.Policy {
+	return &retry.Policy{
+		MaxAttempts: *o.MaxAttempts,
+		Delay:       o.Delay,
+		BaseDelay:   *o.BaseDelay,
+		MaxDelay:    *o.MaxDelay,
+		Backoff:     *o.Backoff,
+	}
+}
+
+func (m *manager) createRequeuer() (*retry.Requeuer, error) {
+	if m.cfg.DeadLetter.Enabled {
+		sink, err := deadletter.NewNsqSink(&deadletter.Config{
+			NsqdTCPAddress: m.cfg.DeadLetter.NsqdTCPAddress,
+			Topic:          m.cfg.DeadLetter.Topic,
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.deadLetter = sink
+	}
+
+	topics := make(map[string]*retry.Policy, len(m.cfg.Retry.Topics))
+	for topic, t := range m.cfg.Retry.Topics {
+		topics[topic] = retryPolicy(m.cfg.Retry.TopicOptions(t))
+	}
+	return retry.NewRequeuer(&retry.Config{
+		Default: *retryPolicy(&m.cfg.Retry.RetryTopicOptions),
+		Topics:  topics,
+	}, m.deadLetter), nil
+}
+
+// createParsers compiles the grok expressions of the topics, the default ones
+// keyed by "".
+func (m *manager) createParsers() error {
+	o := m.cfg.Parse
+	custom := map[string]string{}
+	if o.PatternsDir != "" {
+		var err error
+		if custom, err = processor.ReadGrokPatterns(o.PatternsDir); err != nil {
+			return err
+		}
+	}
+	patterns := processor.GrokPatterns(custom)
+
+	m.parsers = make(map[string]*processor.Parser)
+	create := func(topic string, t *genericoptions.ParseTopicOptions) error {
+		if len(t.Patterns) == 0 {
+			return nil
+		}
+		parser, err := processor.NewParser(t.Patterns, patterns, t.Field, t.MessageField, t.NoMatch, m.requeuer.DeadLetter)
+		if err != nil {
+			return err
+		}
+		m.parsers[topic] = parser
+		return nil
+	}
+	if err := create("", &o.ParseTopicOptions); err != nil {
+		return err
+	}
+	for topic := range o.Topics {
+		if err := create(topic, o.TopicOptions(topic)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parser returns the parser of topic, nil when it is not parsed.
+func (m *manager) parser(topic string) *processor.Parser {
+	if _, ok := m.cfg.Parse.Topics[topic]; ok {
+		return m.parsers[topic]
+	}
+	return m.parsers[""]
+}
+
+// loadSchemas compiles the schemas of the topics, watching their etcd keys
+// for changes when they come from etcd.
+func (m *manager) loadSchemas() error {
+	var (
+		documents map[string][]byte
+		err       error
+	)
+	if m.cfg.Schema.Source == "etcd" {
+		documents, err = m.storeIns.Schemas().Schemas(context.Background())
+	} else {
+		documents, err = processor.ReadSchemas(m.cfg.Schema.Dir)
+	}
 	if err != nil {
-		log.Errorf("New elasticsearch client fail: %v", err)
 		return err
 	}
 
-	err = client.Connect()
+	m.schemas = processor.NewSchemas()
+	for topic, data := range documents {
+		if err := m.schemas.Set(topic, data); err != nil {
+			return err
+		}
+	}
+	log.Infof("Load %d schemas from %s", len(documents), m.cfg.Schema.Source)
+
+	if m.cfg.Schema.Source == "etcd" {
+		return m.storeIns.Watch(context.Background(), m.storeIns.Schemas().Prefix(), m.updateSchema)
+	}
+	return nil
+}
+
+// updateSchema replaces the schema of a changed etcd key, keeping the current
+// one when the new one is invalid.
+func (m *manager) updateSchema(ctx context.Context, key, oldvalue, value []byte) {
+	topic, ok := m.storeIns.Schemas().Topic(string(key))
+	if !ok {
+		return
+	}
+	if len(value) == 0 {
+		log.Infof("Remove schema of %s", topic)
+		m.schemas.Delete(topic)
+		return
+	}
+	if err := m.schemas.Set(topic, value); err != nil {
+		log.Errorf("Update schema fail: %v", err)
+		return
+	}
+	log.Infof("Update schema of %s", topic)
+}
+
+// loadScripts compiles the scripts of the topics, watching their etcd keys
+// for changes when they come from etcd.
+func (m *manager) loadScripts() error {
+	var (
+		sources map[string][]byte
+		err     error
+	)
+	if m.cfg.Script.Source == "etcd" {
+		sources, err = m.storeIns.Scripts().Scripts(context.Background())
+	} else {
+		sources, err = processor.ReadScripts(m.cfg.Script.Dir)
+	}
 	if err != nil {
 		return err
 	}
-	m.esClient = client
 
+	m.scripts = processor.NewScripts(time.Duration(m.cfg.Script.Timeout) * time.Millisecond)
+	for topic, src := range sources {
+		if err := m.scripts.Set(topic, src); err != nil {
+			return err
+		}
+	}
+	log.Infof("Load %d scripts from %s", len(sources), m.cfg.Script.Source)
+
+	if m.cfg.Script.Source == "etcd" {
+		return m.storeIns.Watch(context.Background(), m.storeIns.Scripts().Prefix(), m.updateScript)
+	}
+	return nil
+}
+
+// updateScript replaces the script of a changed etcd key, keeping the current
+// one when the new one is invalid.
+func (m *manager) updateScript(ctx context.Context, key, oldvalue, value []byte) {
+	topic, ok := m.storeIns.Scripts().Topic(string(key))
+	if !ok {
+		return
+	}
+	if len(value) == 0 {
+		log.Infof("Remove script of %s", topic)
+		m.scripts.Delete(topic)
+		return
+	}
+	if err := m.scripts.Set(topic, value); err != nil {
+		log.Errorf("Update script fail: %v", err)
+		return
+	}
+	log.Infof("Update script of %s", topic)
+}
+
+// createEnrichers opens the GeoIP databases and the user agent parser.
+func (m *manager) createEnrichers() error {
+	for _, path := range m.cfg.GeoIP.Databases {
+		db, err := processor.OpenGeoIPDatabase(path, m.cfg.GeoIP.CacheSize)
+		if err != nil {
+			log.Errorf("Open geoip database %s fail: %v", path, err)
+			return err
+		}
+		m.geoIP = append(m.geoIP, db)
+	}
+
+	if m.cfg.UserAgent.Enabled() {
+		parser, err := processor.NewUserAgentParser(m.cfg.UserAgent.Regexes, m.cfg.UserAgent.CacheSize)
+		if err != nil {
+			log.Errorf("Load user agent regexes %s fail: %v", m.cfg.UserAgent.Regexes, err)
+			return err
+		}
+		m.userAgent = parser
+	}
+	return nil
+}
+
+func (m *manager) createDedupStore() (processor.DedupStore, error) {
+	if m.cfg.Dedup.Backend == "redis" {
+		o := m.cfg.Dedup.Redis
+		return processor.NewRedisStore(o.Addr, o.Password, o.DB, o.Prefix)
+	}
+	return processor.NewMemoryStore(m.cfg.Dedup.MaxKeys), nil
+}
+
+func (m *manager) createOutput() (outputs.Client, error) {
+	switch m.cfg.Output.Type {
+	case genericoptions.OutputElasticsearch:
+		topics := make(map[string]*es.TopicConfig, len(m.cfg.Elasticsearch.Topics))
+		for topic, t := range m.cfg.Elasticsearch.Topics {
+			topics[topic] = esTopicConfig(t)
+		}
+		var assets es.Assets
+		if bootstrap := m.cfg.Elasticsearch.Bootstrap; bootstrap.Enabled {
+			if bootstrap.Source == "etcd" {
+				assets = m.storeIns.Elasticsearch()
+			} else {
+				assets = es.NewFileAssets(bootstrap.TemplatesDir, bootstrap.ILMPoliciesDir, bootstrap.TopicTemplate)
+			}
+		}
+		esConfig := func(o *genericoptions.ElasticsearchClusterOptions) *es.Config {
+			return &es.Config{
+				Addrs:         o.Addrs,
+				Username:      o.Username,
+				Password:      o.Password,
+				BatchSize:     o.BatchSize,
+				FlushInterval: o.FlushInterval,
+				QueueSize:     o.QueueSize,
+				Default:       *esTopicConfig(&m.cfg.Elasticsearch.ElasticsearchTopicOptions),
+				Topics:        topics,
+				Assets:        assets,
+			}
+		}
+		if len(m.cfg.Elasticsearch.Clusters) == 0 {
+			return es.NewClient(esConfig(&m.cfg.Elasticsearch.ElasticsearchClusterOptions))
+		}
+		clusters := map[string]*es.Config{
+			es.DefaultCluster: esConfig(&m.cfg.Elasticsearch.ElasticsearchClusterOptions),
+		}
+		for name, c := range m.cfg.Elasticsearch.Clusters {
+			clusters[name] = esConfig(c)
+		}
+		return es.NewClusters(clusters)
+	case genericoptions.OutputFile:
+		return file.NewClient(&file.Config{
+			Path:           m.cfg.File.Path,
+			Codec:          m.cfg.File.Codec,
+			MaxSize:        m.cfg.File.MaxSize,
+			RotateInterval: m.cfg.File.RotateInterval,
+			MaxBackups:     m.cfg.File.MaxBackups,
+			Compress:       m.cfg.File.Compress,
+		})
+	case genericoptions.OutputConsole:
+		return console.NewClient(&console.Config{
+			Format:   m.cfg.Console.Format,
+			Template: m.cfg.Console.Template,
+			Fields:   m.cfg.Console.Fields,
+		})
+	case genericoptions.OutputHTTP:
+		return webhook.NewClient(&webhook.Config{
+			URL:             m.cfg.HTTP.URL,
+			Method:          m.cfg.HTTP.Method,
+			Headers:         m.cfg.HTTP.Headers,
+			Batch:           m.cfg.HTTP.Batch,
+			BatchSize:       m.cfg.HTTP.BatchSize,
+			Secret:          m.cfg.HTTP.Secret,
+			SignatureHeader: m.cfg.HTTP.SignatureHeader,
+			Timeout:         m.cfg.HTTP.Timeout,
+			MaxRetries:      m.cfg.HTTP.MaxRetries,
+			RetryBackoff:    m.cfg.HTTP.RetryBackoff,
+		})
+	case genericoptions.OutputNsq:
+		return nsqout.NewClient(&nsqout.Config{
+			NsqdTCPAddress: m.cfg.NsqOutput.NsqdTCPAddress,
+			Topic:          m.cfg.NsqOutput.Topic,
+			Defer:          m.cfg.NsqOutput.Defer,
+			BatchSize:      m.cfg.NsqOutput.BatchSize,
+		})
+	case genericoptions.OutputClickHouse:
+		return clickhouse.NewClient(&clickhouse.Config{
+			URL:               m.cfg.ClickHouse.URL,
+			Database:          m.cfg.ClickHouse.Database,
+			Username:          m.cfg.ClickHouse.Username,
+			Password:          m.cfg.ClickHouse.Password,
+			Tables:            m.cfg.ClickHouse.Tables,
+			BatchSize:         m.cfg.ClickHouse.BatchSize,
+			FlushInterval:     m.cfg.ClickHouse.FlushInterval,
+			Timeout:           m.cfg.ClickHouse.Timeout,
+			SkipUnknownFields: m.cfg.ClickHouse.SkipUnknownFields,
+		})
+	case genericoptions.OutputLoki:
+		return loki.NewClient(&loki.Config{
+			URL:            m.cfg.Loki.URL,
+			TenantID:       m.cfg.Loki.TenantID,
+			Username:       m.cfg.Loki.Username,
+			Password:       m.cfg.Loki.Password,
+			Labels:         m.cfg.Loki.Labels,
+			TimestampField: m.cfg.Loki.TimestampField,
+			BatchSize:      m.cfg.Loki.BatchSize,
+			Timeout:        m.cfg.Loki.Timeout,
+		})
+	case genericoptions.OutputS3:
+		return s3.NewClient(&s3.Config{
+			Endpoint:      m.cfg.S3.Endpoint,
+			Region:        m.cfg.S3.Region,
+			AccessKey:     m.cfg.S3.AccessKey,
+			SecretKey:     m.cfg.S3.SecretKey,
+			UseSSL:        m.cfg.S3.UseSSL,
+			Bucket:        m.cfg.S3.Bucket,
+			Prefix:        m.cfg.S3.Prefix,
+			Format:        m.cfg.S3.Format,
+			ParquetSchema: m.cfg.S3.ParquetSchema,
+			MaxSize:       m.cfg.S3.MaxSize,
+			FlushInterval: m.cfg.S3.FlushInterval,
+		})
+	case genericoptions.OutputSQL:
+		tables := make(map[string]*sqlout.Table, len(m.cfg.SQL.Topics))
+		for topic, t := range m.cfg.SQL.Topics {
+			tables[topic] = &sqlout.Table{
+				Table:   t.Table,
+				Columns: t.Columns,
+				Keys:    t.Keys,
+			}
+		}
+		return sqlout.NewClient(&sqlout.Config{
+			Driver:       m.cfg.SQL.Driver,
+			DSN:          m.cfg.SQL.DSN,
+			Upsert:       m.cfg.SQL.Upsert,
+			BatchSize:    m.cfg.SQL.BatchSize,
+			MaxOpenConns: m.cfg.SQL.MaxOpenConns,
+			Topics:       tables,
+		})
+	case genericoptions.OutputRedis:
+		return redis.NewClient(&redis.Config{
+			Addr:        m.cfg.Redis.Addr,
+			Username:    m.cfg.Redis.Username,
+			Password:    m.cfg.Redis.Password,
+			DB:          m.cfg.Redis.DB,
+			Mode:        m.cfg.Redis.Mode,
+			Key:         m.cfg.Redis.Key,
+			StreamField: m.cfg.Redis.StreamField,
+			MaxLen:      m.cfg.Redis.MaxLen,
+			BatchSize:   m.cfg.Redis.BatchSize,
+		})
+	case genericoptions.OutputKafka:
+		return kafka.NewClient(&kafka.Config{
+			Brokers:     m.cfg.Kafka.Brokers,
+			Topics:      m.cfg.Kafka.Topics,
+			KeyField:    m.cfg.Kafka.KeyField,
+			Acks:        m.cfg.Kafka.Acks,
+			Compression: m.cfg.Kafka.Compression,
+			BatchSize:   m.cfg.Kafka.BatchSize,
+			BatchBytes:  m.cfg.Kafka.BatchBytes,
+			MaxAttempts: m.cfg.Kafka.MaxAttempts,
+			Timeout:     m.cfg.Kafka.Timeout,
+		})
+	}
+
+	return nil, fmt.Errorf("unsupported output type %q", m.cfg.Output.Type)
+}
+
+func (m *manager) initialize() error {
 	storeIns, err := etcd.GetEtcdFactoryOr(m.cfg.Etcd, nil)
 	if err != nil {
 		return err
 	}
 	store.SetClient(storeIns)
+	m.storeIns = storeIns
+
+	requeuer, err := m.createRequeuer()
+	if err != nil {
+		log.Errorf("New dead letter sink fail: %v", err)
+		return err
+	}
+	message.SetRequeuer(requeuer)
+	m.requeuer = requeuer
+
+	if err := m.createParsers(); err != nil {
+		log.Errorf("Compile parse patterns fail: %v", err)
+		return err
+	}
+
+	if m.cfg.Schema.Enabled {
+		if err := m.loadSchemas(); err != nil {
+			log.Errorf("Load schemas fail: %v", err)
+			return err
+		}
+	}
+
+	if m.cfg.Script.Enabled {
+		if err := m.loadScripts(); err != nil {
+			log.Errorf("Load scripts fail: %v", err)
+			return err
+		}
+	}
+
+	if err := m.createEnrichers(); err != nil {
+		return err
+	}
+
+	if m.cfg.Dedup.Enabled() {
+		if m.dedupStore, err = m.createDedupStore(); err != nil {
+			log.Errorf("New %s dedup store fail: %v", m.cfg.Dedup.Backend, err)
+			return err
+		}
+	}
+
+	client, err := m.createOutput()
+	if err != nil {
+		log.Errorf("New %s output fail: %v", m.cfg.Output.Type, err)
+		return err
+	}
+	if o := m.cfg.Output.Spool; o.Enabled {
+		client, err = spool.NewClient(&spool.Config{
+			Dir:         o.Dir,
+			MaxSize:     o.MaxSize,
+			SegmentSize: o.SegmentSize,
+			MaxInFlight: o.MaxInFlight,
+		}, client)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Connect()
+	if err != nil {
+		return err
+	}
+	m.output = client
 
 	o, err := storeIns.Nsqs().Get(context.Background(), m.cfg.Etcd.Path)
 	if err != nil {
 		return err
 	}
+	if err := errors.NewAggregate(m.cfg.ValidateNsq(o)); err != nil {
+		return err
+	}
 	m.cfg.Nsq = o
+	m.toucher = newToucher()
 
 	err = storeIns.Watch(context.Background(), "", m.updateNsqConfig)
 	if err != nil {
 		return err
 	}
-	m.storeIns = storeIns
 
 	return nil
 }
@@ -93,38 +546,112 @@ func (m *manager) updateNsqConfig(ctx context.Context, key, oldvalue, value []by
 	log.Infof("manager update nsq conifg %s", string(key))
 	log.Infof("%s %s", string(key), m.storeIns.Nsqs().GetKey(m.cfg.Etcd.Path))
 	if string(key) == m.storeIns.Nsqs().GetKey(m.cfg.Etcd.Path) {
+		if value == nil {
+			log.Warnf("nsq config %s is deleted, keep the current one", string(key))
+			return
+		}
 		var o genericoptions.NsqOptions
 		if err := json.Unmarshal(value, &o); err != nil {
 			log.Errorf("failed to unmarshal to nsq options struct, data: %v", string(value))
 			return
 		}
+		if err := errors.NewAggregate(m.cfg.ValidateNsq(&o)); err != nil {
+			log.Errorf("Invalid nsq config %s, keep the current one: %v", string(key), err)
+			return
+		}
 		m.cfg.Nsq = &o
 		m.updateTopics()
 	}
 }
 
+// processors returns the processors of topic.
+func (m *manager) processors(topic string) processor.Chain {
+	var chain processor.Chain
+	if parser := m.parser(topic); parser != nil {
+		chain = append(chain, processor.Step{
+			Name:      "parse",
+			Processor: parser,
+		})
+	}
+	sampling := m.cfg.Sampling.TopicOptions(topic)
+	if sampling.Percent < 100 {
+		chain = append(chain, processor.Step{
+			Name:      "sampling",
+			Processor: processor.NewSampler(sampling.Percent, sampling.KeyField),
+		})
+	}
+	if sampling.RateLimit > 0 {
+		chain = append(chain, processor.Step{
+			Name:      "rate-limit",
+			Processor: processor.NewRateLimiter(sampling.RateLimit, sampling.Burst),
+		})
+	}
+	dedup := m.cfg.Dedup.TopicOptions(topic)
+	if dedup.Enabled() {
+		chain = append(chain, processor.Step{
+			Name:      "dedup",
+			Processor: processor.NewDeduper(m.dedupStore, dedup.Key, dedup.Field, time.Duration(dedup.Window)*time.Second, time.Duration(m.cfg.Nsq.MsgTimeout)*time.Second),
+		})
+	}
+	if m.cfg.Schema.Enabled {
+		schema := m.cfg.Schema.TopicOptions(topic)
+		chain = append(chain, processor.Step{
+			Name:      "schema",
+			Processor: processor.NewValidator(m.schemas, schema.Action, schema.TagField, m.requeuer.DeadLetter),
+		})
+	}
+	if m.cfg.Script.Enabled {
+		chain = append(chain, processor.Step{
+			Name:      "script",
+			Processor: processor.NewScripter(m.scripts),
+		})
+	}
+	if geoIP := m.cfg.GeoIP.TopicOptions(topic); len(m.geoIP) > 0 && geoIP.Field != "" {
+		chain = append(chain, processor.Step{
+			Name:      "geoip",
+			Processor: processor.NewGeoIP(m.geoIP, geoIP.Field, geoIP.Target),
+		})
+	}
+	if userAgent := m.cfg.UserAgent.TopicOptions(topic); m.userAgent != nil && userAgent.Field != "" {
+		chain = append(chain, processor.Step{
+			Name:      "user-agent",
+			Processor: processor.NewUserAgent(m.userAgent, userAgent.Field, userAgent.Target),
+		})
+	}
+	return chain
+}
+
 func (m *manager) updateTopics() {
 	m.nsqConfig.DialTimeout = time.Duration(m.cfg.Nsq.DialTimeout) * time.Second
 	m.nsqConfig.ReadTimeout = time.Duration(m.cfg.Nsq.ReadTimeout) * time.Second
 	m.nsqConfig.WriteTimeout = time.Duration(m.cfg.Nsq.WriteTimeout) * time.Second
 	m.nsqConfig.MaxInFlight = m.cfg.Nsq.MaxInFlight
+	m.nsqConfig.MsgTimeout = time.Duration(m.cfg.Nsq.MsgTimeout) * time.Second
 
 	for _, topic := range m.cfg.Nsq.Topics {
 		if _, ok := m.topics[topic]; ok {
 			continue
 		}
 		log.Infof("launch topic %s", topic)
-		nsqConsumer, err := nsq.NewConsumer(topic, m.cfg.Nsq.Channel, m.nsqConfig)
+		parser := m.parser(topic)
+		nsqConfig := *m.nsqConfig
+		nsqConfig.SampleRate = int32(m.cfg.Sampling.TopicOptions(topic).NsqdSampleRate)
+		nsqConsumer, err := nsq.NewConsumer(topic, m.cfg.Nsq.Channel, &nsqConfig)
 		if err != nil {
 			log.Errorf("nsq.NewConsumer fail: %v", err)
 			continue
 		}
 		nsqConsumer.SetLogger(log.StdInfoLogger(), nsq.LogLevelInfo)
 		consumer := &Consumer{
-			topic:    topic,
-			consumer: nsqConsumer,
-			done:     make(chan struct{}),
-			msgChan:  make(chan *nsq.Message),
+			topic:       topic,
+			consumer:    nsqConsumer,
+			queue:       m.queue,
+			toucher:     m.toucher,
+			processors:  m.processors(topic),
+			deadLetter:  m.requeuer.DeadLetter,
+			parsesText:  parser != nil && parser.ParsesText(),
+			maxInFlight: m.cfg.Nsq.MaxInFlight,
+			msgTimeout:  nsqConfig.MsgTimeout,
 		}
 		nsqConsumer.AddConcurrentHandlers(consumer, runtime.NumCPU())
 		err = nsqConsumer.ConnectToNSQLookupds(m.cfg.Nsq.LookupdHttpAddresses)
@@ -133,17 +660,43 @@ func (m *manager) updateTopics() {
 			continue
 		}
 		m.topics[topic] = consumer
-
-		go func(consumer *Consumer, msgChan chan<- *message.Message) {
-			consumer.Run(msgChan)
-		}(consumer, m.msgChan)
 	}
 }
 
 func (m *manager) launch() error {
 	msgChan := make(chan *message.Message)
-	m.msgChan = msgChan
-	go m.esClient.Run(msgChan)
+	go m.queue.Run(msgChan)
+	m.outputDone = make(chan struct{})
+	go func() {
+		m.output.Run(msgChan)
+		close(m.outputDone)
+	}()
+	m.done = make(chan struct{})
+	go m.toucher.Run(m.done)
+	if len(m.geoIP) > 0 {
+		files := make([]processor.Reloadable, 0, len(m.geoIP))
+		for _, db := range m.geoIP {
+			files = append(files, db)
+		}
+		go processor.Reload(time.Duration(m.cfg.GeoIP.ReloadInterval)*time.Second, m.done, files...)
+	}
+	if m.userAgent != nil && m.cfg.UserAgent.Regexes != "" {
+		go processor.Reload(time.Duration(m.cfg.UserAgent.ReloadInterval)*time.Second, m.done, m.userAgent)
+	}
+	metrics.Publish("queue", func() interface{} {
+		depth, topics := m.queue.Stats()
+		return map[string]interface{}{
+			"depth":    depth,
+			"capacity": m.cfg.Output.QueueSize,
+			"topics":   topics,
+		}
+	})
+	if m.cfg.Metrics.Address != "" {
+		metrics.Serve(m.cfg.Metrics.Address)
+	}
+	if m.cfg.Output.QueueStatsInterval > 0 {
+		go m.queue.Report(time.Duration(m.cfg.Output.QueueStatsInterval)*time.Second, m.done)
+	}
 
 	m.updateTopics()
 
@@ -176,9 +729,17 @@ func (m *manager) Stop() {
 		consumer.Stop()
 	}
 
-	close(m.msgChan)
+	m.queue.Close()
+	<-m.outputDone
+	close(m.done)
 
-	// 最后关闭elasticsearch
-	m.esClient.Close()
+	// 最后关闭output
+	m.output.Close()
+	if m.deadLetter != nil {
+		m.deadLetter.Close()
+	}
+	if m.dedupStore != nil {
+		m.dedupStore.Close()
+	}
 	log.Info("manager stopped")
 }
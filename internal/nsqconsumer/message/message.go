@@ -1,7 +1,9 @@
 package message
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jehiah/go-strftime"
@@ -29,3 +31,50 @@ func (m *Message) GetIndexName() string {
 	now := time.Now()
 	return strftime.Format(fmt.Sprintf("%s-%%y.%%m.%%d", m.topic), now)
 }
+
+// Lookup returns the value at the dotted path of a decoded event, such as
+// "user.id" for {"user": {"id": 1}}.
+func Lookup(event map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = event
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// IsObject reports whether body is a valid json object. It only scans the
+// body, which is much cheaper than decoding it.
+func IsObject(body []byte) bool {
+	for _, b := range body {
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{':
+			return json.Valid(body)
+		}
+		return false
+	}
+	return false
+}
+
+// Set sets the value at the dotted path of a decoded event, creating the
+// missing objects on the way.
+func Set(event map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	obj := event
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[keys[len(keys)-1]] = value
+}
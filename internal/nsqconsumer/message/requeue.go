@@ -0,0 +1,40 @@
+package message
+
+import (
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Requeuer decides how messages the output failed to publish are requeued.
+type Requeuer interface {
+	Requeue(m *Message)
+	DeadLetter(m *Message, reason string)
+}
+
+var requeuer Requeuer
+
+// SetRequeuer sets the Requeuer used by Message.Requeue and
+// Message.DeadLetter.
+func SetRequeuer(r Requeuer) {
+	requeuer = r
+}
+
+// Requeue hands a message the output failed to publish back to nsqd, or to
+// the Requeuer when one is set.
+func (m *Message) Requeue() {
+	if requeuer == nil {
+		m.data.Requeue(-1)
+		return
+	}
+	requeuer.Requeue(m)
+}
+
+// DeadLetter hands a message the output can never publish to the dead letter
+// sink of the Requeuer, dropping it when no Requeuer is set.
+func (m *Message) DeadLetter(reason string) {
+	if requeuer == nil {
+		log.Errorf("Drop %s message %s: %s", m.topic, m.data.ID, reason)
+		m.data.Finish()
+		return
+	}
+	requeuer.DeadLetter(m, reason)
+}
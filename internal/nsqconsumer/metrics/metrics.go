@@ -0,0 +1,46 @@
+// Package metrics publishes the counters of the consumer with expvar, served
+// at /debug/vars when a metrics address is configured.
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+var (
+	mux     sync.Mutex
+	dropped = expvar.NewMap("dropped")
+)
+
+// Dropped counts an event of topic dropped by reason, such as the processor
+// dropping it.
+func Dropped(topic, reason string) {
+	topicMap, ok := dropped.Get(topic).(*expvar.Map)
+	if !ok {
+		mux.Lock()
+		if topicMap, ok = dropped.Get(topic).(*expvar.Map); !ok {
+			topicMap = new(expvar.Map).Init()
+			dropped.Set(topic, topicMap)
+		}
+		mux.Unlock()
+	}
+	topicMap.Add(reason, 1)
+}
+
+// Publish publishes f as the metric name.
+func Publish(name string, f func() interface{}) {
+	expvar.Publish(name, expvar.Func(f))
+}
+
+// Serve serves the metrics at address in the background.
+func Serve(address string) {
+	log.Infof("serve metrics: %s", address)
+	go func() {
+		if err := http.ListenAndServe(address, expvar.Handler()); err != nil {
+			log.Errorf("Serve metrics fail: %v", err)
+		}
+	}()
+}
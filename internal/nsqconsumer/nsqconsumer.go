@@ -1,51 +1,74 @@
 package nsqconsumer
 
 import (
-	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/nsqio/go-nsq"
 
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/metrics"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/processor"
 )
 
 type Consumer struct {
-	topic    string
-	consumer *nsq.Consumer
-	done     chan struct{}
-	msgChan  chan *nsq.Message
+	topic       string
+	consumer    *nsq.Consumer
+	queue       *queue
+	toucher     *toucher
+	processors  processor.Chain
+	maxInFlight int
+	// msgTimeout is the msg-timeout negotiated with nsqd, held messages are
+	// touched before it.
+	msgTimeout time.Duration
+	// parsesText is set when the processors parse text bodies, which are not
+	// dropped as invalid json then.
+	parsesText bool
+	// deadLetter receives the messages the processors fail on.
+	deadLetter processor.DeadLetterFunc
 }
 
 func (c *Consumer) HandleMessage(m *nsq.Message) error {
 	m.DisableAutoResponse()
-	c.msgChan <- m
+	if !c.parsesText && !message.IsObject(m.Body) {
+		log.Infof("Invalid nsq message of %s: not a json object", c.topic)
+		metrics.Dropped(c.topic, "invalid")
+		m.Finish()
+		return nil
+	}
+
+	events, dropper, err := c.processors.Process(processor.NewEvent(c.topic, m))
+	if err != nil {
+		log.Infof("Process %s message by %s fail: %v", c.topic, dropper, err)
+		metrics.Dropped(c.topic, dropper)
+		c.deadLetter(message.NewMessage(m, c.topic), fmt.Sprintf("%s fail: %v", dropper, err))
+		return nil
+	}
+	if len(events) == 0 {
+		metrics.Dropped(c.topic, dropper)
+		m.Finish()
+		return nil
+	}
+
+	c.toucher.Hold(m, c.msgTimeout)
+	for _, e := range events {
+		c.queue.Push(c, message.NewMessage(e.Message, e.Topic))
+	}
 	return nil
 }
 
-func (c *Consumer) Stop() {
-	c.consumer.Stop()
-	<-c.consumer.StopChan
+// pause stops nsqd from sending more messages by lowering RDY to 0.
+func (c *Consumer) pause() {
+	c.consumer.ChangeMaxInFlight(0)
+}
 
-	close(c.done)
+// resume restores the max in flight lowered by pause.
+func (c *Consumer) resume() {
+	c.consumer.ChangeMaxInFlight(c.maxInFlight)
 }
 
-func (c *Consumer) Run(msgChan chan<- *message.Message) {
-	log.Infof("%s consumer running", c.topic)
-	for {
-		select {
-		case <-c.done:
-			log.Infof("Consumer %s done", c.topic)
-			return
-		case m := <-c.msgChan:
-			data := make(map[string]interface{})
-			err := json.Unmarshal(m.Body, &data)
-			// m.Finish()
-			if err != nil {
-				log.Infof("Unmarshal nsq message fail: %v", err)
-				m.Finish()
-			} else {
-				msgChan <- message.NewMessage(m, c.topic)
-			}
-		}
-	}
+func (c *Consumer) Stop() {
+	c.consumer.Stop()
+	<-c.consumer.StopChan
 }
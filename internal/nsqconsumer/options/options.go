@@ -10,7 +10,28 @@ import (
 // Options runs a iam api server.
 type Options struct {
 	Log           *log.Options                         `json:"log" mapstructure:"log"`
+	Output        *genericoptions.OutputOptions        `json:"output" mapstructure:"output"`
 	Elasticsearch *genericoptions.ElasticsearchOptions `json:"elasticsearch" mapstructure:"elasticsearch"`
+	File          *genericoptions.FileOptions          `json:"file" mapstructure:"file"`
+	Console       *genericoptions.ConsoleOptions       `json:"console" mapstructure:"console"`
+	HTTP          *genericoptions.HTTPOptions          `json:"http" mapstructure:"http"`
+	NsqOutput     *genericoptions.NsqOutputOptions     `json:"nsq-output" mapstructure:"nsq-output"`
+	ClickHouse    *genericoptions.ClickHouseOptions    `json:"clickhouse" mapstructure:"clickhouse"`
+	Loki          *genericoptions.LokiOptions          `json:"loki" mapstructure:"loki"`
+	S3            *genericoptions.S3Options            `json:"s3" mapstructure:"s3"`
+	SQL           *genericoptions.SQLOptions           `json:"sql" mapstructure:"sql"`
+	Redis         *genericoptions.RedisOptions         `json:"redis" mapstructure:"redis"`
+	Kafka         *genericoptions.KafkaOptions         `json:"kafka" mapstructure:"kafka"`
+	Retry         *genericoptions.RetryOptions         `json:"retry" mapstructure:"retry"`
+	DeadLetter    *genericoptions.DeadLetterOptions    `json:"dead-letter" mapstructure:"dead-letter"`
+	Parse         *genericoptions.ParseOptions         `json:"parse" mapstructure:"parse"`
+	Sampling      *genericoptions.SamplingOptions      `json:"sampling" mapstructure:"sampling"`
+	Dedup         *genericoptions.DedupOptions         `json:"dedup" mapstructure:"dedup"`
+	Schema        *genericoptions.SchemaOptions        `json:"schema" mapstructure:"schema"`
+	Script        *genericoptions.ScriptOptions        `json:"script" mapstructure:"script"`
+	GeoIP         *genericoptions.GeoIPOptions         `json:"geoip" mapstructure:"geoip"`
+	UserAgent     *genericoptions.UserAgentOptions     `json:"user-agent" mapstructure:"user-agent"`
+	Metrics       *genericoptions.MetricsOptions       `json:"metrics" mapstructure:"metrics"`
 	Nsq           *genericoptions.NsqOptions           `json:"nsq" mapstructure:"nsq"`
 	Etcd          *genericoptions.EtcdOptions          `json:"etcd" mapstructure:"etcd"`
 }
@@ -19,7 +40,28 @@ type Options struct {
 func NewOptions() *Options {
 	o := Options{
 		Log:           log.NewOptions(),
+		Output:        genericoptions.NewOutputOptions(),
 		Elasticsearch: genericoptions.NewElasticsearchOptions(),
+		File:          genericoptions.NewFileOptions(),
+		Console:       genericoptions.NewConsoleOptions(),
+		HTTP:          genericoptions.NewHTTPOptions(),
+		NsqOutput:     genericoptions.NewNsqOutputOptions(),
+		ClickHouse:    genericoptions.NewClickHouseOptions(),
+		Loki:          genericoptions.NewLokiOptions(),
+		S3:            genericoptions.NewS3Options(),
+		SQL:           genericoptions.NewSQLOptions(),
+		Redis:         genericoptions.NewRedisOptions(),
+		Kafka:         genericoptions.NewKafkaOptions(),
+		Retry:         genericoptions.NewRetryOptions(),
+		DeadLetter:    genericoptions.NewDeadLetterOptions(),
+		Parse:         genericoptions.NewParseOptions(),
+		Sampling:      genericoptions.NewSamplingOptions(),
+		Dedup:         genericoptions.NewDedupOptions(),
+		Schema:        genericoptions.NewSchemaOptions(),
+		Script:        genericoptions.NewScriptOptions(),
+		GeoIP:         genericoptions.NewGeoIPOptions(),
+		UserAgent:     genericoptions.NewUserAgentOptions(),
+		Metrics:       genericoptions.NewMetricsOptions(),
 		Nsq:           genericoptions.NewNsqOptionsOptions(),
 		Etcd:          genericoptions.NewEtcdOptions(),
 	}
@@ -30,7 +72,28 @@ func NewOptions() *Options {
 // Flags returns flags for a specific APIServer by section name.
 func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	o.Log.AddFlags(fss.FlagSet("logs"))
+	o.Output.AddFlags(fss.FlagSet("output"))
 	o.Elasticsearch.AddFlags(fss.FlagSet("elasticsearch"))
+	o.File.AddFlags(fss.FlagSet("file"))
+	o.Console.AddFlags(fss.FlagSet("console"))
+	o.HTTP.AddFlags(fss.FlagSet("http"))
+	o.NsqOutput.AddFlags(fss.FlagSet("nsq-output"))
+	o.ClickHouse.AddFlags(fss.FlagSet("clickhouse"))
+	o.Loki.AddFlags(fss.FlagSet("loki"))
+	o.S3.AddFlags(fss.FlagSet("s3"))
+	o.SQL.AddFlags(fss.FlagSet("sql"))
+	o.Redis.AddFlags(fss.FlagSet("redis"))
+	o.Kafka.AddFlags(fss.FlagSet("kafka"))
+	o.Retry.AddFlags(fss.FlagSet("retry"))
+	o.DeadLetter.AddFlags(fss.FlagSet("dead-letter"))
+	o.Parse.AddFlags(fss.FlagSet("parse"))
+	o.Sampling.AddFlags(fss.FlagSet("sampling"))
+	o.Dedup.AddFlags(fss.FlagSet("dedup"))
+	o.Schema.AddFlags(fss.FlagSet("schema"))
+	o.Script.AddFlags(fss.FlagSet("script"))
+	o.GeoIP.AddFlags(fss.FlagSet("geoip"))
+	o.UserAgent.AddFlags(fss.FlagSet("user-agent"))
+	o.Metrics.AddFlags(fss.FlagSet("metrics"))
 	o.Nsq.AddFlags(fss.FlagSet("nsq"))
 	o.Etcd.AddFlags(fss.FlagSet("etcd"))
 	return fss
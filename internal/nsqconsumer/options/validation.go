@@ -1,8 +1,105 @@
 package options
 
+import (
+	"fmt"
+
+	genericoptions "github.com/JieTrancender/nsq-tool-kit/internal/pkg/options"
+)
+
 // Validate checks Options and return a slice of found errs.
 func (o *Options) Validate() []error {
 	var errs []error
 
+	errs = append(errs, o.Output.Validate()...)
+	switch o.Output.Type {
+	case genericoptions.OutputElasticsearch:
+		errs = append(errs, o.Elasticsearch.Validate()...)
+	case genericoptions.OutputFile:
+		errs = append(errs, o.File.Validate()...)
+	case genericoptions.OutputConsole:
+		errs = append(errs, o.Console.Validate()...)
+	case genericoptions.OutputHTTP:
+		errs = append(errs, o.HTTP.Validate()...)
+	case genericoptions.OutputNsq:
+		errs = append(errs, o.NsqOutput.Validate()...)
+	case genericoptions.OutputClickHouse:
+		errs = append(errs, o.ClickHouse.Validate()...)
+	case genericoptions.OutputLoki:
+		errs = append(errs, o.Loki.Validate()...)
+	case genericoptions.OutputS3:
+		errs = append(errs, o.S3.Validate()...)
+	case genericoptions.OutputSQL:
+		errs = append(errs, o.SQL.Validate()...)
+	case genericoptions.OutputRedis:
+		errs = append(errs, o.Redis.Validate()...)
+	case genericoptions.OutputKafka:
+		errs = append(errs, o.Kafka.Validate()...)
+	}
+	errs = append(errs, o.Retry.Validate()...)
+	errs = append(errs, o.DeadLetter.Validate()...)
+	errs = append(errs, o.Parse.Validate()...)
+	errs = append(errs, o.Sampling.Validate()...)
+	errs = append(errs, o.Dedup.Validate()...)
+	errs = append(errs, o.Schema.Validate()...)
+	errs = append(errs, o.Script.Validate()...)
+	errs = append(errs, o.GeoIP.Validate()...)
+	errs = append(errs, o.UserAgent.Validate()...)
+	errs = append(errs, o.Metrics.Validate()...)
+
+	return errs
+}
+
+// ValidateNsq checks the nsq options loaded from etcd, which replace Nsq.
+func (o *Options) ValidateNsq(nsq *genericoptions.NsqOptions) []error {
+	errs := nsq.Validate()
+	errs = append(errs, o.validateBatchSize(nsq.MaxInFlight)...)
+
+	return errs
+}
+
+// validateBatchSize checks that a full batch of the selected output fits in
+// flight: the output never holds more unfinished messages than max-in-flight,
+// so a larger batch would only ever be flushed by its timer.
+func (o *Options) validateBatchSize(maxInFlight int) []error {
+	name := "nsq max-in-flight"
+	if o.Output.Spool.Enabled {
+		name, maxInFlight = "output spool max-in-flight", o.Output.Spool.MaxInFlight
+	}
+
+	var batchSizes map[string]int
+	switch o.Output.Type {
+	case genericoptions.OutputElasticsearch:
+		batchSizes = map[string]int{genericoptions.ElasticsearchDefaultCluster: o.Elasticsearch.BatchSize}
+		for cluster, c := range o.Elasticsearch.Clusters {
+			batchSizes[cluster] = c.BatchSize
+		}
+	case genericoptions.OutputHTTP:
+		batchSizes = map[string]int{"": o.HTTP.BatchSize}
+	case genericoptions.OutputNsq:
+		batchSizes = map[string]int{"": o.NsqOutput.BatchSize}
+	case genericoptions.OutputClickHouse:
+		batchSizes = map[string]int{"": o.ClickHouse.BatchSize}
+	case genericoptions.OutputLoki:
+		batchSizes = map[string]int{"": o.Loki.BatchSize}
+	case genericoptions.OutputSQL:
+		batchSizes = map[string]int{"": o.SQL.BatchSize}
+	case genericoptions.OutputRedis:
+		batchSizes = map[string]int{"": o.Redis.BatchSize}
+	case genericoptions.OutputKafka:
+		batchSizes = map[string]int{"": o.Kafka.BatchSize}
+	}
+
+	var errs []error
+	for cluster, batchSize := range batchSizes {
+		if batchSize <= maxInFlight {
+			continue
+		}
+		if cluster == "" {
+			errs = append(errs, fmt.Errorf("%s must be at least the %s batch-size %d", name, o.Output.Type, batchSize))
+		} else {
+			errs = append(errs, fmt.Errorf("%s must be at least the %s batch-size %d of cluster %s", name, o.Output.Type, batchSize, cluster))
+		}
+	}
+
 	return errs
 }
@@ -0,0 +1,54 @@
+package outputs
+
+import (
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// RunBatch reads messages from msgChan and passes them to publish in batches of
+// at most batchSize, flushing a partial batch every flushInterval. It returns
+// after msgChan is closed and the remaining messages are published. The batch
+// slice is reused, so publish must not keep it after returning.
+func RunBatch(
+	msgChan <-chan *message.Message,
+	batchSize int,
+	flushInterval time.Duration,
+	publish func([]*message.Message),
+) {
+	timer := time.NewTimer(flushInterval)
+	defer timer.Stop()
+
+	msgList := make([]*message.Message, 0, batchSize)
+	for {
+		select {
+		case m, ok := <-msgChan:
+			if !ok {
+				if len(msgList) > 0 {
+					publish(msgList)
+				}
+				return
+			}
+			msgList = append(msgList, m)
+			if len(msgList) >= batchSize {
+				publish(msgList)
+				msgList = reset(msgList)
+			}
+		case <-timer.C:
+			if len(msgList) > 0 {
+				publish(msgList)
+				msgList = reset(msgList)
+			}
+			timer.Reset(flushInterval)
+		}
+	}
+}
+
+// reset empties msgList for reuse, dropping the references to the published
+// messages.
+func reset(msgList []*message.Message) []*message.Message {
+	for i := range msgList {
+		msgList[i] = nil
+	}
+	return msgList[:0]
+}
@@ -0,0 +1,160 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+// Client inserts messages into clickhouse tables through the http interface.
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	if _, err := url.Parse(config.URL); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		config: config,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s", c.config.URL)
+	c.client = &http.Client{
+		Timeout: time.Duration(c.config.Timeout) * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, strings.TrimRight(c.config.URL, "/")+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping clickhouse fail: %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("clickhouse %s publish", c.config.URL)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Duration(c.config.FlushInterval)*time.Second, c.Publish)
+	log.Infof("clickhouse %s close", c.config.URL)
+}
+
+func (c *Client) table(topic string) string {
+	if table, ok := c.config.Tables[topic]; ok {
+		return table
+	}
+	return topic
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	tables := make([]string, 0)
+	groups := make(map[string][]*message.Message)
+	rows := make(map[string]*bytes.Buffer)
+	for _, m := range msgList {
+		table := c.table(m.GetTopic())
+		buf, ok := rows[table]
+		if !ok {
+			buf = &bytes.Buffer{}
+			rows[table] = buf
+			tables = append(tables, table)
+		}
+
+		n := buf.Len()
+		if err := json.Compact(buf, m.GetData().Body); err != nil {
+			buf.Truncate(n)
+			log.Infof("Compact nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("compact fail: %v", err))
+			continue
+		}
+		buf.WriteByte('\n')
+		groups[table] = append(groups[table], m)
+	}
+
+	for _, table := range tables {
+		group := groups[table]
+		if len(group) == 0 {
+			continue
+		}
+		err := c.insert(table, rows[table].Bytes())
+		for _, m := range group {
+			if err != nil {
+				m.Requeue()
+			} else {
+				m.GetData().Finish()
+			}
+		}
+		if err != nil {
+			log.Errorf("Insert %d rows into %s fail: %v", len(group), table, err)
+		}
+	}
+}
+
+// insert sends rows, one json object per line, to table.
+func (c *Client) insert(table string, rows []byte) error {
+	query := fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", quoteIdentifier(c.config.Database), quoteIdentifier(table))
+	params := url.Values{}
+	params.Set("query", query)
+	if c.config.SkipUnknownFields {
+		params.Set("input_format_skip_unknown_fields", "1")
+	}
+
+	u := strings.TrimRight(c.config.URL, "/") + "/?" + params.Encode()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, u, bytes.NewReader(rows))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.config.Username != "" {
+		req.Header.Set("X-ClickHouse-User", c.config.Username)
+		req.Header.Set("X-ClickHouse-Key", c.config.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
@@ -0,0 +1,185 @@
+package clickhouse
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// insert is an INSERT request received by the server.
+type insert struct {
+	query  string
+	skip   string
+	user   string
+	key    string
+	rows   string
+	format string
+}
+
+// server is a fake clickhouse http interface answering inserts with status.
+type server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	status  int
+	inserts []insert
+}
+
+func newServer(t *testing.T, status int) *server {
+	s := &server{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			_, _ = io.WriteString(w, "Ok.\n")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		s.mu.Lock()
+		s.inserts = append(s.inserts, insert{
+			query:  r.URL.Query().Get("query"),
+			skip:   r.URL.Query().Get("input_format_skip_unknown_fields"),
+			user:   r.Header.Get("X-ClickHouse-User"),
+			key:    r.Header.Get("X-ClickHouse-Key"),
+			rows:   string(body),
+			format: r.Header.Get("Content-Type"),
+		})
+		s.mu.Unlock()
+		if s.status != http.StatusOK {
+			http.Error(w, "Code: 60. DB::Exception: Table doesn't exist", s.status)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *server) received() []insert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inserts
+}
+
+func newClient(t *testing.T, config *Config) *Client {
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestPublish(t *testing.T) {
+	s := newServer(t, http.StatusOK)
+	c := newClient(t, &Config{
+		URL:               s.URL,
+		Database:          "logs",
+		Username:          "writer",
+		Password:          "secret",
+		Tables:            map[string]string{"access": "access_log"},
+		SkipUnknownFields: true,
+	})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := append(d.Messages("access", `{"path": "/a"}`, `not json`, `{"path": "/b"}`),
+		d.Messages("error", `{"msg": "boom"}`)...)
+
+	c.Publish(msgList)
+
+	want := []insert{
+		{
+			query:  "INSERT INTO `logs`.`access_log` FORMAT JSONEachRow",
+			rows:   "{\"path\":\"/a\"}\n{\"path\":\"/b\"}\n",
+			skip:   "1",
+			user:   "writer",
+			key:    "secret",
+			format: "application/x-ndjson",
+		},
+		{
+			query:  "INSERT INTO `logs`.`error` FORMAT JSONEachRow",
+			rows:   "{\"msg\":\"boom\"}\n",
+			skip:   "1",
+			user:   "writer",
+			key:    "secret",
+			format: "application/x-ndjson",
+		},
+	}
+	got := s.received()
+	if len(got) != len(want) {
+		t.Fatalf("got %d inserts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("insert %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	for i, m := range msgList {
+		want := outputstest.Finished
+		if i == 1 {
+			want = outputstest.DeadLettered
+		}
+		if got := d.Response(m); got != want {
+			t.Errorf("message %d response = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestPublishQuoteIdentifier(t *testing.T) {
+	s := newServer(t, http.StatusOK)
+	c := newClient(t, &Config{URL: s.URL + "/", Database: "default"})
+	d := outputstest.NewDelegate()
+
+	c.Publish(d.Messages("odd`name", `{"a":1}`))
+
+	got := s.received()
+	if len(got) != 1 {
+		t.Fatalf("got %d inserts, want 1", len(got))
+	}
+	if want := "INSERT INTO `default`.`odd\\`name` FORMAT JSONEachRow"; got[0].query != want {
+		t.Errorf("query = %s, want %s", got[0].query, want)
+	}
+	if got[0].skip != "" || got[0].user != "" {
+		t.Errorf("insert = %+v, want no skip setting nor credentials", got[0])
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	s := newServer(t, http.StatusNotFound)
+	c := newClient(t, &Config{URL: s.URL, Database: "logs"})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("access", `{"path": "/a"}`, `{"path": "/b"}`, `not json`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList[:2] {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+	if got := d.Response(msgList[2]); got != outputstest.DeadLettered {
+		t.Errorf("invalid message response = %d, want dead-lettered", got)
+	}
+}
+
+func TestPublishRequeueUnreachable(t *testing.T) {
+	s := newServer(t, http.StatusOK)
+	c := newClient(t, &Config{URL: s.URL, Database: "logs"})
+	s.Close()
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("access", `{"path": "/a"}`)
+
+	c.Publish(msgList)
+
+	if got := d.Response(msgList[0]); got != outputstest.Requeued {
+		t.Errorf("response = %d, want requeued", got)
+	}
+}
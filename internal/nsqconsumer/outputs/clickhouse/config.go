@@ -0,0 +1,13 @@
+package clickhouse
+
+type Config struct {
+	URL               string            `config:"url" json:"url"`
+	Database          string            `config:"database" json:"database"`
+	Username          string            `config:"username" json:"username"`
+	Password          string            `config:"password" json:"password"`
+	Tables            map[string]string `config:"tables" json:"tables"`
+	BatchSize         int               `config:"batch_size" json:"batch_size"`
+	FlushInterval     int               `config:"flush_interval" json:"flush_interval"`
+	Timeout           int               `config:"timeout" json:"timeout"`
+	SkipUnknownFields bool              `config:"skip_unknown_fields" json:"skip_unknown_fields"`
+}
@@ -0,0 +1,163 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+const (
+	formatJSON     = "json"
+	formatPretty   = "pretty"
+	formatTemplate = "template"
+)
+
+// Client prints messages to stdout.
+type Client struct {
+	config *Config
+	tmpl   *outputs.Template
+	w      io.Writer
+	out    *bufio.Writer
+
+	mux sync.Mutex
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config: config,
+		w:      os.Stdout,
+		out:    bufio.NewWriter(os.Stdout),
+	}
+	if config.Format == formatTemplate {
+		tmpl, err := outputs.NewTemplate("console", config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse console template fail: %w", err)
+		}
+		c.tmpl = tmpl
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.out.Flush()
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("console %s publish", c.config.Format)
+	for m := range msgChan {
+		c.Publish([]*message.Message{m})
+	}
+	log.Info("console close")
+}
+
+// fields returns the configured subset of event, keyed by field path.
+func (c *Client) fields(event map[string]interface{}) map[string]interface{} {
+	if len(c.config.Fields) == 0 {
+		return event
+	}
+
+	subset := make(map[string]interface{}, len(c.config.Fields))
+	for _, path := range c.config.Fields {
+		if v, ok := message.Lookup(event, path); ok {
+			subset[path] = v
+		}
+	}
+	return subset
+}
+
+func (c *Client) format(w io.Writer, m *message.Message) error {
+	data := m.GetData()
+	if c.config.Format == formatJSON && len(c.config.Fields) == 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data.Body); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	event := make(map[string]interface{})
+	if err := json.Unmarshal(data.Body, &event); err != nil {
+		return err
+	}
+	fields := c.fields(event)
+
+	switch c.config.Format {
+	case formatTemplate:
+		line, err := c.tmpl.Execute(outputs.NewEvent(m, fields))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, line+"\n")
+		return err
+	case formatPretty:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(fields)
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(fields)
+	}
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	printed := make([]*message.Message, 0, len(msgList))
+	var line bytes.Buffer
+	for _, m := range msgList {
+		line.Reset()
+		if err := c.format(&line, m); err != nil {
+			log.Infof("Format nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("format fail: %v", err))
+			continue
+		}
+		if _, err := c.out.Write(line.Bytes()); err != nil {
+			log.Errorf("Write console fail: %v", err)
+			// The error of a bufio.Writer is sticky, reset it along with
+			// the lines buffered so far.
+			c.out.Reset(c.w)
+			for _, p := range printed {
+				p.Requeue()
+			}
+			printed = printed[:0]
+			m.Requeue()
+			continue
+		}
+		printed = append(printed, m)
+	}
+
+	err := c.out.Flush()
+	for _, m := range printed {
+		if err != nil {
+			m.Requeue()
+		} else {
+			m.GetData().Finish()
+		}
+	}
+	if err != nil {
+		log.Errorf("Flush console fail: %v", err)
+		c.out.Reset(c.w)
+	}
+}
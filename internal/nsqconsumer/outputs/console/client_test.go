@@ -0,0 +1,111 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func newClient(t *testing.T, config *Config, w io.Writer) *Client {
+	t.Helper()
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.w = w
+	c.out = bufio.NewWriter(w)
+	return c
+}
+
+func TestPublish(t *testing.T) {
+	body := `{"level": "info", "user": {"id": 7}, "msg": "<hi>"}`
+	tests := []struct {
+		name   string
+		config *Config
+		want   string
+	}{
+		{name: "json", config: &Config{Format: formatJSON}, want: `{"level":"info","user":{"id":7},"msg":"<hi>"}` + "\n"},
+		{name: "fields", config: &Config{Format: formatJSON, Fields: []string{"user.id", "msg", "missing"}}, want: `{"msg":"<hi>","user.id":7}` + "\n"},
+		{name: "pretty", config: &Config{Format: formatPretty, Fields: []string{"level"}}, want: "{\n  \"level\": \"info\"\n}\n"},
+		{name: "template", config: &Config{Format: formatTemplate, Template: "{{.Topic}} [{{.Fields.level}}] {{.Fields.msg}}"}, want: "logs [info] <hi>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := newClient(t, tt.config, &out)
+			d := outputstest.NewDelegate()
+			msgList := d.Messages("logs", body)
+
+			c.Publish(msgList)
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if got := d.Response(msgList[0]); got != outputstest.Finished {
+				t.Errorf("response = %d, want finished", got)
+			}
+		})
+	}
+}
+
+func TestPublishFormatFail(t *testing.T) {
+	var out bytes.Buffer
+	c := newClient(t, &Config{Format: formatTemplate, Template: "{{.Fields.missing}}"}, &out)
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("logs", `{"level":"info"}`, `not json`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.DeadLettered {
+			t.Errorf("message %d response = %d, want dead-lettered", i, got)
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want none", out.String())
+	}
+}
+
+// flakyWriter fails the writes while fail is set.
+type flakyWriter struct {
+	bytes.Buffer
+	fail bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("broken pipe")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestPublishRecoversFromWriteFail(t *testing.T) {
+	w := &flakyWriter{fail: true}
+	c := newClient(t, &Config{Format: formatJSON}, w)
+	d := outputstest.NewDelegate()
+	failed := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(failed)
+
+	for i, m := range failed {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+
+	w.fail = false
+	msgList := d.Messages("logs", `{"n":3}`)
+	c.Publish(msgList)
+
+	if got := d.Response(msgList[0]); got != outputstest.Finished {
+		t.Errorf("response = %d after the writer recovered, want finished", got)
+	}
+	if want := "{\"n\":3}\n"; w.String() != want {
+		t.Errorf("output = %q, want %q", w.String(), want)
+	}
+}
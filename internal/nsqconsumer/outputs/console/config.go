@@ -0,0 +1,7 @@
+package console
+
+type Config struct {
+	Format   string   `config:"format" json:"format"`
+	Template string   `config:"template" json:"template"`
+	Fields   []string `config:"fields" json:"fields"`
+}
@@ -0,0 +1,188 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/olivere/elastic/v7"
+)
+
+// Assets loads the composable index templates and ILM policies installed by
+// the client, keyed by name.
+type Assets interface {
+	IndexTemplates(ctx context.Context) (map[string][]byte, error)
+	ILMPolicies(ctx context.Context) (map[string][]byte, error)
+	// TopicTemplate returns the index template installed for every topic,
+	// with {topic} replaced by the topic name, nil when there is none.
+	TopicTemplate(ctx context.Context) ([]byte, error)
+}
+
+type fileAssets struct {
+	templatesDir  string
+	policiesDir   string
+	topicTemplate string
+}
+
+// NewFileAssets loads assets from the *.json files of local directories, each
+// file named after its template or policy.
+func NewFileAssets(templatesDir, policiesDir, topicTemplate string) Assets {
+	return &fileAssets{
+		templatesDir:  templatesDir,
+		policiesDir:   policiesDir,
+		topicTemplate: topicTemplate,
+	}
+}
+
+func readDir(dir string) (map[string][]byte, error) {
+	assets := make(map[string][]byte)
+	if dir == "" {
+		return assets, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		assets[strings.TrimSuffix(filepath.Base(file), ".json")] = data
+	}
+	return assets, nil
+}
+
+func (a *fileAssets) IndexTemplates(context.Context) (map[string][]byte, error) {
+	return readDir(a.templatesDir)
+}
+
+func (a *fileAssets) ILMPolicies(context.Context) (map[string][]byte, error) {
+	return readDir(a.policiesDir)
+}
+
+func (a *fileAssets) TopicTemplate(context.Context) ([]byte, error) {
+	if a.topicTemplate == "" {
+		return nil, nil
+	}
+	return os.ReadFile(a.topicTemplate)
+}
+
+// bootstrap installs the ILM policies and index templates of the assets.
+func (c *Client) bootstrap(ctx context.Context) error {
+	policies, err := c.assets.ILMPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("load ilm policies fail: %w", err)
+	}
+	for name, body := range policies {
+		if err := c.putILMPolicy(ctx, name, body); err != nil {
+			return fmt.Errorf("put ilm policy %s fail: %w", name, err)
+		}
+	}
+
+	templates, err := c.assets.IndexTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("load index templates fail: %w", err)
+	}
+	for name, body := range templates {
+		if err := c.putIndexTemplate(ctx, name, body); err != nil {
+			return fmt.Errorf("put index template %s fail: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// bootstrapTopic installs the topic template of topic once.
+func (c *Client) bootstrapTopic(ctx context.Context, topic string) {
+	if c.assets == nil || c.bootstrapped[topic] {
+		return
+	}
+
+	body, err := c.assets.TopicTemplate(ctx)
+	if err != nil {
+		log.Errorf("Load topic template of %s fail: %v", topic, err)
+		return
+	}
+	if body != nil {
+		body = []byte(strings.ReplaceAll(string(body), "{topic}", topic))
+		if err := c.putIndexTemplate(ctx, topic, body); err != nil {
+			log.Errorf("Put index template %s fail: %v", topic, err)
+			return
+		}
+	}
+	c.bootstrapped[topic] = true
+}
+
+// putIndexTemplate installs a composable index template unless one with the
+// same version exists. Templates without a version are only installed when
+// missing.
+func (c *Client) putIndexTemplate(ctx context.Context, name string, body []byte) error {
+	var local struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(body, &local); err != nil {
+		return err
+	}
+
+	resp, err := c.client.IndexGetIndexTemplate(name).Do(ctx)
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if t, ok := resp.IndexTemplates.ByName(name); ok {
+			if local.Version == nil || t.IndexTemplate.Version == *local.Version {
+				return nil
+			}
+		}
+	}
+
+	log.Infof("put index template %s", name)
+	_, err = c.client.IndexPutIndexTemplate(name).BodyString(string(body)).Do(ctx)
+	return err
+}
+
+// policyVersion returns policy._meta.version of an ILM policy.
+func policyVersion(policy map[string]interface{}) (interface{}, bool) {
+	meta, ok := policy["_meta"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	version, ok := meta["version"]
+	return version, ok
+}
+
+// putILMPolicy installs an ILM policy unless one with the same _meta.version
+// exists. Policies without a version are only installed when missing.
+func (c *Client) putILMPolicy(ctx context.Context, name string, body []byte) error {
+	var local struct {
+		Policy map[string]interface{} `json:"policy"`
+	}
+	if err := json.Unmarshal(body, &local); err != nil {
+		return err
+	}
+
+	resp, err := c.client.XPackIlmGetLifecycle().Policy(name).Do(ctx)
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if p, ok := resp[name]; ok {
+			version, ok := policyVersion(local.Policy)
+			if !ok {
+				return nil
+			}
+			if current, ok := policyVersion(p.Policy); ok && fmt.Sprint(current) == fmt.Sprint(version) {
+				return nil
+			}
+		}
+	}
+
+	log.Infof("put ilm policy %s", name)
+	_, err = c.client.XPackIlmPutLifecycle().Policy(name).BodyString(string(body)).Do(ctx)
+	return err
+}
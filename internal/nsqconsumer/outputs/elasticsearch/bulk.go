@@ -0,0 +1,151 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer bounds the capacity of bulk bodies kept for reuse, so one
+// oversized batch doesn't pin its memory.
+const maxPooledBuffer = 16 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// bulkResponse is the part of a bulk response telling the failed items.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	// Items hold the result of each item keyed by its operation type.
+	Items []map[string]*bulkResult `json:"items"`
+}
+
+type bulkResult struct {
+	Status int        `json:"status"`
+	Error  *bulkError `json:"error"`
+}
+
+type bulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e *bulkError) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.Type + ": " + e.Reason
+}
+
+// itemResult returns the operation type and result of a bulk response item,
+// nil when the item is malformed.
+func itemResult(item map[string]*bulkResult) (string, *bulkResult) {
+	for op, result := range item {
+		return op, result
+	}
+	return "", nil
+}
+
+// appendBulkItem appends the action and document lines of a bulk item to buf.
+// body must be a valid json object, it is copied as is unless it spans
+// several lines.
+func appendBulkItem(buf *bytes.Buffer, opType, index, id, routing, pipeline string, body []byte) error {
+	buf.WriteString(`{"`)
+	buf.WriteString(opType)
+	buf.WriteString(`":{"_index":`)
+	appendString(buf, index)
+	if id != "" {
+		buf.WriteString(`,"_id":`)
+		appendString(buf, id)
+	}
+	if routing != "" {
+		buf.WriteString(`,"routing":`)
+		appendString(buf, routing)
+	}
+	if pipeline != "" && opType != OpTypeUpdate {
+		buf.WriteString(`,"pipeline":`)
+		appendString(buf, pipeline)
+	}
+	buf.WriteString("}}\n")
+
+	if opType == OpTypeUpdate {
+		buf.WriteString(`{"doc":`)
+	}
+	mark := buf.Len()
+	if bytes.IndexByte(body, '\n') >= 0 {
+		if err := json.Compact(buf, body); err != nil {
+			buf.Truncate(mark)
+			return err
+		}
+	} else {
+		buf.Write(body)
+	}
+	if opType == OpTypeUpdate {
+		buf.WriteString(`,"doc_as_upsert":true}`)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s to buf as a json string.
+func appendString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				buf.WriteString(s[start:i])
+				buf.WriteString(`�`)
+				i += size
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if b >= 0x20 && b != '"' && b != '\\' {
+			i++
+			continue
+		}
+		buf.WriteString(s[start:i])
+		switch b {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(b)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hexDigits[b>>4])
+			buf.WriteByte(hexDigits[b&0xf])
+		}
+		i++
+		start = i
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
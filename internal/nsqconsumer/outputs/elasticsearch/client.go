@@ -2,9 +2,13 @@ package elasticsearch
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/olivere/elastic/v7"
 
 	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
 )
 
 type Client struct {
@@ -21,6 +26,16 @@ type Client struct {
 	username string
 	password string
 
+	batchSize     int
+	flushInterval time.Duration
+	queueSize     int
+
+	defaultTopic *TopicConfig
+	topics       map[string]*TopicConfig
+
+	assets       Assets
+	bootstrapped map[string]bool
+
 	mux sync.Mutex
 }
 
@@ -29,6 +44,25 @@ func NewClient(config *Config) (*Client, error) {
 		addrs:    config.Addrs,
 		username: config.Username,
 		password: config.Password,
+
+		batchSize:     config.BatchSize,
+		flushInterval: time.Duration(config.FlushInterval) * time.Second,
+		queueSize:     config.QueueSize,
+
+		defaultTopic: &config.Default,
+		topics:       make(map[string]*TopicConfig, len(config.Topics)),
+
+		assets:       config.Assets,
+		bootstrapped: make(map[string]bool),
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 100
+	}
+	if c.flushInterval <= 0 {
+		c.flushInterval = time.Second
+	}
+	for topic, tc := range config.Topics {
+		c.topics[topic] = tc.merge(&config.Default)
 	}
 	return c, nil
 }
@@ -58,6 +92,10 @@ func (c *Client) Connect() error {
 	}
 
 	c.client = client
+
+	if c.assets != nil {
+		return c.bootstrap(context.Background())
+	}
 	return nil
 }
 
@@ -69,75 +107,208 @@ func (c *Client) Close() error {
 
 func (c *Client) Run(msgChan <-chan *message.Message) {
 	log.Infof("elasticsearch %v publish", c.addrs)
+	outputs.RunBatch(msgChan, c.batchSize, c.flushInterval, c.Publish)
+	log.Infof("elasticsearch %v close", c.addrs)
+}
 
-	timeout := time.Second * 1
-	var timer *time.Timer
-	maxCount := 100
-	msgList := make([]*message.Message, 0)
-	for {
-		if timer == nil {
-			timer = time.NewTimer(timeout)
-		} else {
-			if len(msgList) == maxCount {
-				timer.Reset(timeout)
-				c.Publish(msgList)
-				msgList = make([]*message.Message, 0)
-			}
-		}
+// indexName returns the index or data stream documents of topic are written to.
+func (c *Client) indexName(tc *TopicConfig, topic string, now time.Time) string {
+	name := tc.Index
+	if tc.DataStream != "" {
+		name = tc.DataStream
+	}
+	return strftime.Format(strings.ReplaceAll(name, "{topic}", topic), now)
+}
 
-		select {
-		case m, ok := <-msgChan:
-			if !ok {
-				log.Infof("elasticsearch %v close", c.addrs)
-				return
-			}
-			msgList = append(msgList, m)
-		case <-timer.C:
-			if len(msgList) > 0 {
-				c.Publish(msgList)
-				msgList = make([]*message.Message, 0)
+func (c *Client) topicConfig(topic string) *TopicConfig {
+	if tc, ok := c.topics[topic]; ok {
+		return tc
+	}
+	return c.defaultTopic
+}
+
+// documentID returns the _id of a message, empty to let elasticsearch
+// generate one.
+func documentID(tc *TopicConfig, m *message.Message, data map[string]interface{}) (string, error) {
+	switch tc.DocumentID {
+	case DocumentIDMessageID:
+		id := m.GetData().ID
+		return string(id[:]), nil
+	case DocumentIDField:
+		values := make([]string, 0, len(tc.IDFields))
+		for _, field := range tc.IDFields {
+			v, ok := message.Lookup(data, field)
+			if !ok || v == nil {
+				return "", fmt.Errorf("missing id field %s", field)
 			}
-			timer.Reset(timeout)
+			values = append(values, fieldString(v))
 		}
+		return strings.Join(values, "_"), nil
+	case DocumentIDHash:
+		values := make([]interface{}, 0, len(tc.IDFields))
+		for _, field := range tc.IDFields {
+			v, _ := message.Lookup(data, field)
+			values = append(values, v)
+		}
+		b, err := json.Marshal(values)
+		if err != nil {
+			return "", err
+		}
+		sum := sha1.Sum(b)
+		return hex.EncodeToString(sum[:]), nil
 	}
+	return "", nil
 }
 
-func (c *Client) indexName(topic string) string {
-	now := time.Now()
-	return strftime.Format(fmt.Sprintf("%s-%%Y.%%m.%%d", topic), now)
+// fieldString formats a decoded json value, keeping integers out of
+// exponent notation.
+func fieldString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// opType returns the bulk operation of the topic. Data streams only accept
+// create.
+func opType(tc *TopicConfig) string {
+	if tc.DataStream != "" {
+		return OpTypeCreate
+	}
+	if tc.OpType == "" {
+		return OpTypeIndex
+	}
+	return tc.OpType
+}
+
+// needsFields reports whether the topic reads fields of the event, which
+// requires decoding the body.
+func needsFields(tc *TopicConfig) bool {
+	return tc.DocumentID == DocumentIDField || tc.DocumentID == DocumentIDHash || tc.RoutingField != ""
 }
 
+// routing returns the routing value of data, empty when no routing field is
+// configured or the field is missing.
+func routing(tc *TopicConfig, data map[string]interface{}) string {
+	if tc.RoutingField == "" {
+		return ""
+	}
+	v, ok := message.Lookup(data, tc.RoutingField)
+	if !ok || v == nil {
+		return ""
+	}
+	return fieldString(v)
+}
+
+// Publish sends the messages in one bulk request. Bodies are validated by
+// the consumer and copied into the request as is, they are only decoded for
+// topics whose document id or routing is taken from fields.
 func (c *Client) Publish(msgList []*message.Message) {
-	bulkReq := elastic.NewBulkService(c.client)
-	defer bulkReq.Reset()
-	removeList := make([]int, 0)
-	for i, m := range msgList {
-		data := make(map[string]interface{})
-		err := json.Unmarshal(m.GetData().Body, &data)
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	now := time.Now()
+	indices := make(map[string]string)
+	pending := make([]*message.Message, 0, len(msgList))
+	for _, m := range msgList {
+		topic := m.GetTopic()
+		c.bootstrapTopic(context.Background(), topic)
+		tc := c.topicConfig(topic)
+
+		var data map[string]interface{}
+		if needsFields(tc) {
+			if err := json.Unmarshal(m.GetData().Body, &data); err != nil {
+				log.Infof("Unmarshal %s message fail: %v", topic, err)
+				m.DeadLetter(fmt.Sprintf("unmarshal fail: %v", err))
+				continue
+			}
+		}
+		id, err := documentID(tc, m, data)
+		if err != nil {
+			log.Infof("Get document id of %s message fail: %v", topic, err)
+			m.DeadLetter(fmt.Sprintf("document id fail: %v", err))
+			continue
+		}
+		index, ok := indices[topic]
+		if !ok {
+			index = c.indexName(tc, topic, now)
+			indices[topic] = index
+		}
+		err = appendBulkItem(buf, opType(tc), index, id, routing(tc, data), tc.Pipeline, m.GetData().Body)
 		if err != nil {
-			m.GetData().Requeue(-1)
-			removeList = append(removeList, i)
+			log.Infof("Encode %s message fail: %v", topic, err)
+			m.DeadLetter(fmt.Sprintf("encode fail: %v", err))
 			continue
 		}
-		req := elastic.NewBulkIndexRequest().Index(c.indexName(m.GetTopic())).Doc(data)
-		bulkReq = bulkReq.Add(req)
+		pending = append(pending, m)
 	}
-
-	for i := len(removeList) - 1; i >= 0; i-- {
-		log.Infof("remove i = %d", i)
-		msgList = append(msgList[:i], msgList[i+1:]...)
+	if len(pending) == 0 {
+		return
 	}
 
-	bulkResp, err := bulkReq.Do(context.Background())
+	resp, err := c.client.PerformRequest(context.Background(), elastic.PerformRequestOptions{
+		Method:      "POST",
+		Path:        "/_bulk",
+		Body:        buf.String(),
+		ContentType: "application/x-ndjson",
+	})
 	if err != nil {
-		log.Infof("Do bulk request fail: %v %v", err, bulkResp)
-		for _, message := range msgList {
-			message.GetData().Requeue(-1)
+		log.Infof("Do bulk request fail: %v", err)
+		for _, message := range pending {
+			message.Requeue()
 		}
 		return
 	}
-	for _, m := range msgList {
-		m.GetData().Finish()
+	respond(pending, resp.Body)
+}
+
+// respond finishes the messages of the succeeded bulk items. The items
+// rejected with 429 or 5xx are requeued, the others are dead-lettered. A
+// document that already exists is a success for create, which makes
+// redelivered messages idempotent.
+func respond(pending []*message.Message, body []byte) {
+	var resp bulkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		log.Errorf("Decode bulk response fail: %v", err)
+		for _, m := range pending {
+			m.Requeue()
+		}
+		return
+	}
+	if !resp.Errors {
+		for _, m := range pending {
+			m.GetData().Finish()
+		}
+		return
+	}
+	if len(resp.Items) != len(pending) {
+		log.Errorf("Bulk response has %d items for %d messages", len(resp.Items), len(pending))
+		for _, m := range pending {
+			m.Requeue()
+		}
+		return
+	}
+
+	var failed int
+	for i, m := range pending {
+		op, result := itemResult(resp.Items[i])
+		switch {
+		case result == nil:
+			failed++
+			m.Requeue()
+		case result.Status >= 200 && result.Status < 300:
+			m.GetData().Finish()
+		case result.Status == http.StatusConflict && op == OpTypeCreate:
+			m.GetData().Finish()
+		case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+			failed++
+			m.Requeue()
+		default:
+			failed++
+			m.DeadLetter(fmt.Sprintf("bulk %s status %d: %s", op, result.Status, result.Error))
+		}
+	}
+	if failed > 0 {
+		log.Warnf("%d of %d bulk items fail", failed, len(pending))
 	}
-	// log.Infof("耗时: %v, 索引数目: %d", bulkResp.Took, len(bulkResp.Items))
 }
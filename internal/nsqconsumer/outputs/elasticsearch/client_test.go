@@ -0,0 +1,191 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// bulkHandler is a fake _bulk endpoint answering each item with the status
+// in the "status" field of its document, 201 when there is none.
+func bulkHandler(tb testing.TB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var resp bytes.Buffer
+		resp.WriteString(`{"took":1,"errors":`)
+		items := make([]string, 0)
+		hasErrors := false
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		for scanner.Scan() {
+			var action map[string]struct {
+				Index string `json:"_index"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				tb.Errorf("decode action: %v", err)
+			}
+			if !scanner.Scan() {
+				tb.Errorf("missing document of %s", scanner.Text())
+				break
+			}
+			var doc struct {
+				Status int `json:"status"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				tb.Errorf("decode document: %v", err)
+			}
+			if doc.Status == 0 {
+				doc.Status = http.StatusCreated
+			}
+			for op, a := range action {
+				item := fmt.Sprintf(`{%q:{"_index":%q,"status":%d`, op, a.Index, doc.Status)
+				if doc.Status >= 300 {
+					hasErrors = true
+					item += fmt.Sprintf(`,"error":{"type":"test_exception","reason":"status %d"}`, doc.Status)
+				}
+				items = append(items, item+"}}")
+			}
+		}
+		resp.WriteString(strconv.FormatBool(hasErrors))
+		resp.WriteString(`,"items":[`)
+		for i, item := range items {
+			if i > 0 {
+				resp.WriteByte(',')
+			}
+			resp.WriteString(item)
+		}
+		resp.WriteString("]}")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(resp.Bytes())
+	}
+}
+
+func newTestClient(tb testing.TB, handler http.Handler, config *Config) *Client {
+	c, err := NewClient(config)
+	if err != nil {
+		tb.Fatalf("NewClient: %v", err)
+	}
+	connectTestServer(tb, c, handler)
+	return c
+}
+
+// connectTestServer connects c to a test server answering with handler.
+func connectTestServer(tb testing.TB, c *Client, handler http.Handler) {
+	s := httptest.NewServer(handler)
+	tb.Cleanup(s.Close)
+
+	var err error
+	c.client, err = elastic.NewClient(elastic.SetURL(s.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		tb.Fatalf("elastic.NewClient: %v", err)
+	}
+	tb.Cleanup(c.client.Stop)
+}
+
+func TestPublishBulkItems(t *testing.T) {
+	c := newTestClient(t, bulkHandler(t), &Config{
+		Default: TopicConfig{Index: "{topic}"},
+		Topics: map[string]*TopicConfig{
+			"events": {OpType: OpTypeCreate},
+			"users":  {DocumentID: DocumentIDField, IDFields: []string{"id"}},
+		},
+	})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	tests := []struct {
+		topic string
+		body  string
+		want  int
+	}{
+		{topic: "logs", body: `{"n":1}`, want: outputstest.Finished},
+		{topic: "logs", body: `{"status":200}`, want: outputstest.Finished},
+		{topic: "events", body: `{"status":409}`, want: outputstest.Finished},
+		{topic: "logs", body: `{"status":409}`, want: outputstest.DeadLettered},
+		{topic: "logs", body: `{"status":400}`, want: outputstest.DeadLettered},
+		{topic: "logs", body: `{"status":429}`, want: outputstest.Requeued},
+		{topic: "events", body: `{"status":503}`, want: outputstest.Requeued},
+		{topic: "users", body: `{"id":7}`, want: outputstest.Finished},
+		{topic: "users", body: `{"name":"x"}`, want: outputstest.DeadLettered},
+		{topic: "users", body: `not json`, want: outputstest.DeadLettered},
+	}
+	msgList := make([]*message.Message, 0, len(tests))
+	for _, tt := range tests {
+		msgList = append(msgList, d.Messages(tt.topic, tt.body)...)
+	}
+
+	c.Publish(msgList)
+
+	for i, tt := range tests {
+		if got := d.Response(msgList[i]); got != tt.want {
+			t.Errorf("%s %s response = %d, want %d", tt.topic, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestPublishBulkRequestFail(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"overloaded"}`, http.StatusServiceUnavailable)
+	}), &Config{Default: TopicConfig{Index: "{topic}"}})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	const batchSize = 100
+
+	// Answer with a prepared response, so the benchmark measures the client.
+	var resp bytes.Buffer
+	resp.WriteString(`{"took":1,"errors":false,"items":[`)
+	for i := 0; i < batchSize; i++ {
+		if i > 0 {
+			resp.WriteByte(',')
+		}
+		resp.WriteString(`{"index":{"_index":"logs-2022.07.01","status":201}}`)
+	}
+	resp.WriteString("]}")
+	c := newTestClient(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(resp.Bytes())
+	}), &Config{Default: TopicConfig{Index: "{topic}-%Y.%m.%d"}})
+
+	d := outputstest.NewDelegate()
+	body := `{"@timestamp":"2022-07-01T12:00:00Z","level":"info","service":"api","msg":"request served","latency_ms":12}`
+	bodies := make([]string, batchSize)
+	for i := range bodies {
+		bodies[i] = body
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		msgList := d.Messages("logs", bodies...)
+		b.StartTimer()
+		c.Publish(msgList)
+	}
+}
@@ -0,0 +1,109 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// DefaultCluster names the cluster topics without a Cluster are published to.
+const DefaultCluster = "default"
+
+// Clusters publishes the messages of each topic to the cluster selected by
+// its TopicConfig. Every cluster batches from its own queue, so a slow cluster
+// only holds up the others once its queue is full. Run then waits for room
+// instead of requeueing, leaving the output queue to fill up and pause the
+// topics of the slow cluster.
+type Clusters struct {
+	clients map[string]*Client
+}
+
+// NewClusters returns the clusters of configs keyed by name, which must
+// include DefaultCluster. Topic settings are taken from the default cluster.
+func NewClusters(configs map[string]*Config) (*Clusters, error) {
+	if _, ok := configs[DefaultCluster]; !ok {
+		return nil, fmt.Errorf("missing %s elasticsearch cluster", DefaultCluster)
+	}
+
+	cs := &Clusters{clients: make(map[string]*Client, len(configs))}
+	for name, config := range configs {
+		client, err := NewClient(config)
+		if err != nil {
+			return nil, err
+		}
+		if client.queueSize <= 0 {
+			client.queueSize = client.batchSize
+		}
+		cs.clients[name] = client
+	}
+	return cs, nil
+}
+
+func (cs *Clusters) Connect() error {
+	for name, client := range cs.clients {
+		if err := client.Connect(); err != nil {
+			return fmt.Errorf("connect elasticsearch cluster %s fail: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (cs *Clusters) Close() error {
+	for _, client := range cs.clients {
+		client.Close()
+	}
+	return nil
+}
+
+// cluster returns the name of the cluster messages of topic are published to.
+func (cs *Clusters) cluster(topic string) string {
+	name := cs.clients[DefaultCluster].topicConfig(topic).Cluster
+	if _, ok := cs.clients[name]; !ok {
+		return DefaultCluster
+	}
+	return name
+}
+
+func (cs *Clusters) Run(msgChan <-chan *message.Message) {
+	var wg sync.WaitGroup
+	queues := make(map[string]chan *message.Message, len(cs.clients))
+	for name, client := range cs.clients {
+		queue := make(chan *message.Message, client.queueSize)
+		queues[name] = queue
+
+		wg.Add(1)
+		go func(client *Client, queue <-chan *message.Message) {
+			defer wg.Done()
+			client.Run(queue)
+		}(client, queue)
+	}
+
+	for m := range msgChan {
+		name := cs.cluster(m.GetTopic())
+		select {
+		case queues[name] <- m:
+		default:
+			log.Warnf("elasticsearch cluster %s queue is full, wait to publish %s message", name, m.GetTopic())
+			queues[name] <- m
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+}
+
+func (cs *Clusters) Publish(msgList []*message.Message) {
+	groups := make(map[string][]*message.Message)
+	for _, m := range msgList {
+		name := cs.cluster(m.GetTopic())
+		groups[name] = append(groups[name], m)
+	}
+	for name, group := range groups {
+		cs.clients[name].Publish(group)
+	}
+}
@@ -0,0 +1,66 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func TestClustersRunWaitsForFullQueue(t *testing.T) {
+	cs, err := NewClusters(map[string]*Config{
+		DefaultCluster: {
+			Default: TopicConfig{Index: "{topic}"},
+			Topics:  map[string]*TopicConfig{"slow": {Cluster: "slow"}},
+		},
+		"slow": {BatchSize: 1, QueueSize: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewClusters: %v", err)
+	}
+	release := make(chan struct{})
+	connectTestServer(t, cs.clients[DefaultCluster], bulkHandler(t))
+	connectTestServer(t, cs.clients["slow"], http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		bulkHandler(t)(w, r)
+	}))
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("slow", `{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`)
+
+	msgChan := make(chan *message.Message)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cs.Run(msgChan)
+	}()
+
+	// One message is being published, one is queued and Run holds the third
+	// until the queue has room, so it can't take the fourth.
+	for _, m := range msgList[:3] {
+		msgChan <- m
+	}
+	select {
+	case msgChan <- msgList[3]:
+		t.Fatal("Run took a message while the slow cluster queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.None {
+			t.Errorf("message %d response = %d before the cluster caught up, want none", i, got)
+		}
+	}
+
+	close(release)
+	msgChan <- msgList[3]
+	close(msgChan)
+	<-done
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Finished {
+			t.Errorf("message %d response = %d, want finished", i, got)
+		}
+	}
+}
@@ -1,7 +1,82 @@
 package elasticsearch
 
+// Document _id strategies.
+const (
+	DocumentIDAuto      = "auto"
+	DocumentIDField     = "field"
+	DocumentIDHash      = "hash"
+	DocumentIDMessageID = "message-id"
+)
+
+// Bulk operation types.
+const (
+	OpTypeIndex  = "index"
+	OpTypeCreate = "create"
+	OpTypeUpdate = "update"
+)
+
+// TopicConfig defines how the messages of a topic are indexed.
+type TopicConfig struct {
+	// Cluster names the cluster of Clusters the topic is published to,
+	// empty for the default one.
+	Cluster string `config:"cluster" json:"cluster"`
+	// Index and DataStream are name templates, {topic} and strftime verbs
+	// are expanded. Documents are created in DataStream when it is set.
+	Index        string   `config:"index" json:"index"`
+	DataStream   string   `config:"data_stream" json:"data_stream"`
+	Pipeline     string   `config:"pipeline" json:"pipeline"`
+	RoutingField string   `config:"routing_field" json:"routing_field"`
+	DocumentID   string   `config:"document_id" json:"document_id"`
+	IDFields     []string `config:"id_fields" json:"id_fields"`
+	OpType       string   `config:"op_type" json:"op_type"`
+}
+
+// merge returns a copy of tc with empty settings taken from def.
+func (tc *TopicConfig) merge(def *TopicConfig) *TopicConfig {
+	merged := *tc
+	if merged.Cluster == "" {
+		merged.Cluster = def.Cluster
+	}
+	if merged.Index == "" {
+		merged.Index = def.Index
+	}
+	if merged.DataStream == "" {
+		merged.DataStream = def.DataStream
+	}
+	if merged.Pipeline == "" {
+		merged.Pipeline = def.Pipeline
+	}
+	if merged.RoutingField == "" {
+		merged.RoutingField = def.RoutingField
+	}
+	if merged.DocumentID == "" {
+		merged.DocumentID = def.DocumentID
+	}
+	if len(merged.IDFields) == 0 {
+		merged.IDFields = def.IDFields
+	}
+	if merged.OpType == "" {
+		merged.OpType = def.OpType
+	}
+	return &merged
+}
+
 type Config struct {
 	Addrs    []string `config:"addrs" json:"addrs"`
 	Username string   `config:"username" json:"username"`
 	Password string   `config:"password" json:"password"`
+
+	// BatchSize messages are sent in a bulk request, a partial batch after
+	// FlushInterval seconds. QueueSize messages are buffered by Clusters.
+	BatchSize     int `config:"batch_size" json:"batch_size"`
+	FlushInterval int `config:"flush_interval" json:"flush_interval"`
+	QueueSize     int `config:"queue_size" json:"queue_size"`
+
+	// Default applies to topics without an entry in Topics.
+	Default TopicConfig             `config:"default" json:"default"`
+	Topics  map[string]*TopicConfig `config:"topics" json:"topics"`
+
+	// Assets are installed on connect and when a topic is first published,
+	// nil disables installing them.
+	Assets Assets `config:"-" json:"-"`
 }
@@ -0,0 +1,165 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jehiah/go-strftime"
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+const (
+	codecJSON = "json"
+	codecRaw  = "raw"
+)
+
+// Client writes messages to local files, one file per topic.
+type Client struct {
+	config *Config
+
+	writers map[string]*rotateWriter
+	archive sync.Mutex
+	wg      sync.WaitGroup
+
+	mux sync.Mutex
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config:  config,
+		writers: make(map[string]*rotateWriter),
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("file output: %s", c.config.Path)
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for topic, w := range c.writers {
+		if err := w.Close(); err != nil {
+			log.Errorf("close file %s fail: %v", w.path, err)
+		}
+		delete(c.writers, topic)
+	}
+	c.wg.Wait()
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("file %s publish", c.config.Path)
+	outputs.RunBatch(msgChan, 100, time.Second, c.Publish)
+	log.Infof("file %s close", c.config.Path)
+}
+
+// pathPattern returns the path template of topic, before the time is
+// expanded.
+func (c *Client) pathPattern(topic string) string {
+	return strings.ReplaceAll(c.config.Path, "{topic}", topic)
+}
+
+// fileName expands the path template for topic at the current time.
+func (c *Client) fileName(topic string) string {
+	return strftime.Format(c.pathPattern(topic), time.Now())
+}
+
+// writer returns the writer of topic, switching files when the expanded
+// path has changed since the last write.
+func (c *Client) writer(topic string) *rotateWriter {
+	path := c.fileName(topic)
+	var lost error
+	if w, ok := c.writers[topic]; ok {
+		if w.path == path {
+			return w
+		}
+		if err := w.Close(); err != nil {
+			log.Errorf("close file %s fail: %v", w.path, err)
+			lost = err
+		} else {
+			lost = w.err
+		}
+	}
+
+	w := &rotateWriter{
+		path:       path,
+		pattern:    c.pathPattern(topic),
+		maxSize:    int64(c.config.MaxSize) * 1024 * 1024,
+		interval:   time.Duration(c.config.RotateInterval) * time.Second,
+		maxBackups: c.config.MaxBackups,
+		compress:   c.config.Compress,
+		archive:    &c.archive,
+		wg:         &c.wg,
+		// The messages of the batch written to the previous file are
+		// requeued when it lost them.
+		err: lost,
+	}
+	c.writers[topic] = w
+	return w
+}
+
+func (c *Client) encode(buf *bytes.Buffer, body []byte) error {
+	switch c.config.Codec {
+	case codecRaw:
+		buf.Write(bytes.TrimRight(body, "\r\n"))
+	case codecJSON:
+		if err := json.Compact(buf, body); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported codec %q", c.config.Codec)
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	written := make(map[string][]*message.Message)
+	var buf bytes.Buffer
+	for _, m := range msgList {
+		buf.Reset()
+		if err := c.encode(&buf, m.GetData().Body); err != nil {
+			log.Infof("Encode nsq message fail: %v", err)
+			m.GetData().Finish()
+			continue
+		}
+
+		w := c.writer(m.GetTopic())
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Errorf("Write file %s fail: %v", w.path, err)
+			m.Requeue()
+			continue
+		}
+		written[m.GetTopic()] = append(written[m.GetTopic()], m)
+	}
+
+	for topic, list := range written {
+		w := c.writers[topic]
+		err := w.Flush()
+		for _, m := range list {
+			if err != nil {
+				m.Requeue()
+			} else {
+				m.GetData().Finish()
+			}
+		}
+		if err != nil {
+			log.Errorf("Flush file %s fail: %v", w.path, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jehiah/go-strftime"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func TestPublish(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		codec string
+		want  map[string]string
+	}{
+		{codec: codecJSON, want: map[string]string{"a": "{\"n\":1}\n{\"n\":3}\n", "b": "{\"n\":2}\n"}},
+		{codec: codecRaw, want: map[string]string{"a": "{\"n\": 1}\n{\n \"n\": 3\n}\n", "b": "{\"n\": 2}\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			pattern := filepath.Join(dir, tt.codec, "{topic}", "%Y%m%d.log")
+			c, err := NewClient(&Config{Path: pattern, Codec: tt.codec})
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			d := outputstest.NewDelegate()
+			var msgList []*message.Message
+			msgList = append(msgList, d.Messages("a", `{"n": 1}`)...)
+			msgList = append(msgList, d.Messages("b", `{"n": 2}`)...)
+			msgList = append(msgList, d.Messages("a", "{\n \"n\": 3\n}\r\n")...)
+
+			c.Publish(msgList)
+			if err := c.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			for i, m := range msgList {
+				if got := d.Response(m); got != outputstest.Finished {
+					t.Errorf("message %d response = %d, want finished", i, got)
+				}
+			}
+			// Closing moves the files aside, which rotation names after the time.
+			for topic, want := range tt.want {
+				path := strftime.Format(filepath.Join(dir, tt.codec, topic, "%Y%m%d.log"), time.Now())
+				backups, err := listBackups(path)
+				if err != nil || len(backups) != 1 {
+					t.Fatalf("backups of %s = %v %v, want one", path, backups, err)
+				}
+				data, err := os.ReadFile(backups[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", topic, data, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package file
+
+type Config struct {
+	Path           string `config:"path" json:"path"`
+	Codec          string `config:"codec" json:"codec"`
+	MaxSize        int    `config:"max_size" json:"max_size"`
+	RotateInterval int    `config:"rotate_interval" json:"rotate_interval"`
+	MaxBackups     int    `config:"max_backups" json:"max_backups"`
+	Compress       bool   `config:"compress" json:"compress"`
+}
@@ -0,0 +1,307 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// rotateWriter appends to a single file and rotates it by size and age.
+type rotateWriter struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+
+	// pattern is path before the time is expanded, the rotated files of
+	// every day count towards maxBackups.
+	pattern string
+
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+	openedAt time.Time
+	// flushed is the size of the file up to the last successful flush, and
+	// err the write error the next Flush reports.
+	flushed int64
+	err     error
+
+	// archive serializes compression and pruning of rotated files.
+	archive *sync.Mutex
+	wg      *sync.WaitGroup
+}
+
+func (w *rotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.size = info.Size()
+	w.flushed = w.size
+	w.openedAt = time.Now()
+	return nil
+}
+
+// Write buffers p. Once a write fails, the following ones fail too until
+// Flush reports the error.
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.buf.Write(p)
+	if err != nil {
+		return 0, w.discard(err)
+	}
+	w.size += int64(n)
+	return n, nil
+}
+
+func (w *rotateWriter) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && w.size+n > w.maxSize {
+		return true
+	}
+	return w.interval > 0 && time.Since(w.openedAt) >= w.interval
+}
+
+// Flush writes the buffered data to the file, returning the error of a failed
+// Write since the last Flush, whose data is lost.
+func (w *rotateWriter) Flush() error {
+	if err := w.err; err != nil {
+		w.err = nil
+		return err
+	}
+	if w.buf == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.discard(err)
+		w.err = nil
+		return err
+	}
+	w.flushed = w.size
+	return nil
+}
+
+// discard drops the data buffered since the last flush after err, truncating
+// what reached the file. The errors of a bufio.Writer are sticky, so the file
+// is closed to be reopened with a fresh writer by the next Write.
+func (w *rotateWriter) discard(err error) error {
+	w.err = err
+	if w.file == nil {
+		return err
+	}
+	if terr := w.file.Truncate(w.flushed); terr != nil {
+		log.Errorf("truncate file %s fail: %v", w.path, terr)
+	}
+	w.file.Close()
+	w.file = nil
+	w.buf = nil
+	w.size = w.flushed
+	return err
+}
+
+// rotate closes the current file, moves it aside and opens a fresh one.
+func (w *rotateWriter) rotate() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close flushes and closes the current file and archives it.
+func (w *rotateWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return w.discard(err)
+	}
+	w.flushed = w.size
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	w.buf = nil
+
+	if w.size == 0 {
+		return nil
+	}
+	backup := backupName(w.path, time.Now())
+	if err := os.Rename(w.path, backup); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.archive.Lock()
+		defer w.archive.Unlock()
+
+		if w.compress {
+			if err := compressFile(backup); err != nil {
+				log.Errorf("compress %s fail: %v", backup, err)
+			}
+		}
+		w.prune()
+	}()
+	return nil
+}
+
+// backupName returns an unused name to move path aside to on rotation.
+func backupName(path string, t time.Time) string {
+	name := path + "." + t.Format("20060102150405.000")
+	for i := 1; ; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s_%d", name, i)
+		}
+		if !exists(candidate) && !exists(candidate+".gz") {
+			return candidate
+		}
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// timeDirective matches the strftime directives of a path pattern.
+var timeDirective = regexp.MustCompile(`%[A-Za-z]`)
+
+// listBackups returns the rotated files of the paths pattern expands to, oldest
+// first.
+func listBackups(pattern string) ([]string, error) {
+	names, err := filepath.Glob(timeDirective.ReplaceAllString(pattern, "*") + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	// The glob also matches the files of paths sharing a prefix, such as
+	// those of other topics, which the expanded time can't contain.
+	var expr strings.Builder
+	expr.WriteByte('^')
+	last := 0
+	for _, loc := range timeDirective.FindAllStringIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		expr.WriteString(`[0-9A-Za-z]+`)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString(`\.[0-9]{14}\.[0-9]{3}`)
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name    string
+		modTime time.Time
+	}
+	list := make([]backup, 0, len(names))
+	for _, name := range names {
+		if !re.MatchString(name) {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		list = append(list, backup{name: name, modTime: info.ModTime()})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].modTime.Equal(list[j].modTime) {
+			return list[i].modTime.Before(list[j].modTime)
+		}
+		return list[i].name < list[j].name
+	})
+
+	result := make([]string, 0, len(list))
+	for _, b := range list {
+		result = append(result, b.name)
+	}
+	return result, nil
+}
+
+// prune removes the oldest rotated files beyond maxBackups.
+func (w *rotateWriter) prune() {
+	if w.maxBackups <= 0 {
+		return
+	}
+	backups, err := listBackups(w.pattern)
+	if err != nil {
+		log.Errorf("list backups of %s fail: %v", w.pattern, err)
+		return
+	}
+	if len(backups) <= w.maxBackups {
+		return
+	}
+
+	for _, name := range backups[:len(backups)-w.maxBackups] {
+		if err := os.Remove(name); err != nil {
+			log.Errorf("remove backup %s fail: %v", name, err)
+		}
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	return os.Remove(name)
+}
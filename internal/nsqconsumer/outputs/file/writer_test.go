@@ -0,0 +1,134 @@
+package file
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestWriter(path string) *rotateWriter {
+	return &rotateWriter{
+		path:    path,
+		pattern: path,
+		archive: &sync.Mutex{},
+		wg:      &sync.WaitGroup{},
+	}
+}
+
+func TestRotateWriterRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.log")
+	w := newTestWriter(path)
+	w.maxSize = 10
+	w.maxBackups = 2
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	w.wg.Wait()
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatalf("listBackups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the latest 2", backups)
+	}
+	var lines []string
+	for _, name := range backups {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+	}
+	sort.Strings(lines)
+	if strings.Join(lines, "") != "line 3\nline 4\n" {
+		t.Errorf("backups hold %q, want the last two lines", lines)
+	}
+}
+
+func TestListBackupsAcrossDays(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"t-20261017.log.20261017230000.000.gz",
+		"t-20261018.log.20261018230000.000",
+		"t-20261019.log.20261019100000.000",
+		"t-20261019.log",
+		"t-x-20261018.log.20261018000000.000",
+	}
+	now := time.Now()
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := newTestWriter(filepath.Join(dir, "t-20261019.log"))
+	w.pattern = filepath.Join(dir, "t-%Y%m%d.log")
+	w.maxBackups = 2
+	w.prune()
+
+	backups, err := listBackups(w.pattern)
+	if err != nil {
+		t.Fatalf("listBackups: %v", err)
+	}
+	want := []string{filepath.Join(dir, names[1]), filepath.Join(dir, names[2])}
+	if strings.Join(backups, ",") != strings.Join(want, ",") {
+		t.Errorf("backups = %v, want %v", backups, want)
+	}
+	for _, name := range names[3:] {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was pruned: %v", name, err)
+		}
+	}
+}
+
+func TestRotateWriterRecoversFromWriteError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to fail writes")
+	}
+	w := newTestWriter("/dev/full")
+	large := bytes.Repeat([]byte("x"), 8<<10)
+
+	if _, err := w.Write(large); err == nil {
+		t.Fatal("Write to /dev/full succeeded")
+	}
+	if _, err := w.Write([]byte("later\n")); err == nil {
+		t.Error("Write after a failed write succeeded before Flush reported it")
+	}
+	if err := w.Flush(); err == nil {
+		t.Error("Flush didn't report the failed write")
+	}
+
+	path := filepath.Join(t.TempDir(), "logs.log")
+	w.path = path
+	if _, err := w.Write([]byte("recovered\n")); err != nil {
+		t.Fatalf("Write after Flush: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "recovered\n" {
+		t.Errorf("file = %q, want the line written after the failure", data)
+	}
+}
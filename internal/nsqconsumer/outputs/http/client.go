@@ -0,0 +1,151 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+// Client sends messages to an http endpoint.
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config: config,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s %s", c.config.Method, c.config.URL)
+	c.client = &http.Client{
+		Timeout: time.Duration(c.config.Timeout) * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("http %s publish", c.config.URL)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("http %s close", c.config.URL)
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	valid := make([]*message.Message, 0, len(msgList))
+	bodies := make([][]byte, 0, len(msgList))
+	for _, m := range msgList {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, m.GetData().Body); err != nil {
+			log.Infof("Compact nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("compact fail: %v", err))
+			continue
+		}
+		valid = append(valid, m)
+		bodies = append(bodies, buf.Bytes())
+	}
+
+	if !c.config.Batch {
+		for i, m := range valid {
+			c.send(bodies[i], []*message.Message{m})
+		}
+		return
+	}
+	if len(valid) > 0 {
+		body := append([]byte{'['}, bytes.Join(bodies, []byte{','})...)
+		c.send(append(body, ']'), valid)
+	}
+}
+
+// send posts body and finishes msgList on a 2xx response, requeueing them otherwise.
+func (c *Client) send(body []byte, msgList []*message.Message) {
+	err := c.do(body)
+	for _, m := range msgList {
+		if err != nil {
+			m.Requeue()
+		} else {
+			m.GetData().Finish()
+		}
+	}
+	if err != nil {
+		log.Errorf("Send %d messages to %s fail: %v", len(msgList), c.config.URL, err)
+	}
+}
+
+// do sends body, retrying network errors, 429 and 5xx responses with an
+// exponential backoff.
+func (c *Client) do(body []byte) error {
+	backoff := time.Duration(c.config.RetryBackoff) * time.Second
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = c.request(body)
+		if err == nil || !retry || attempt >= c.config.MaxRetries {
+			return err
+		}
+
+		log.Warnf("Request %s fail, retry after %v: %v", c.config.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (c *Client) request(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), c.config.Method, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if c.config.Secret != "" {
+		req.Header.Set(c.config.SignatureHeader, Sign(c.config.Secret, body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign returns the signature header value of body, "sha256=" followed by the
+// hex encoded HMAC-SHA256 of body keyed by secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// server records the requests it receives and responds with the status
+// returned by status for the nth request.
+type server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	bodies  []string
+	headers []http.Header
+}
+
+func newServer(t *testing.T, status func(n int) int) *server {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		s.mu.Lock()
+		n := len(s.bodies)
+		s.bodies = append(s.bodies, string(body))
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		w.WriteHeader(status(n))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// requests returns the bodies and headers of the received requests.
+func (s *server) requests() ([]string, []http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies, s.headers
+}
+
+// always responds to every request with code.
+func always(code int) func(int) int {
+	return func(int) int { return code }
+}
+
+func newClient(t *testing.T, config *Config) *Client {
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestPublishBatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		batch  bool
+		bodies []string
+	}{
+		{name: "batch", batch: true, bodies: []string{`[{"a":1},{"b":2}]`}},
+		{name: "single", batch: false, bodies: []string{`{"a":1}`, `{"b":2}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t, always(http.StatusOK))
+			c := newClient(t, &Config{URL: s.URL, Batch: tt.batch})
+			d := outputstest.NewDelegate()
+			d.SetRequeuer(t)
+			msgList := d.Messages("test", `{"a": 1}`, `not json`, `{"b": 2}`)
+
+			c.Publish(msgList)
+
+			bodies, headers := s.requests()
+			if len(bodies) != len(tt.bodies) {
+				t.Fatalf("got %d requests, want %d", len(bodies), len(tt.bodies))
+			}
+			for i, body := range tt.bodies {
+				if bodies[i] != body {
+					t.Errorf("request %d body = %s, want %s", i, bodies[i], body)
+				}
+				if ct := headers[i].Get("Content-Type"); ct != "application/json" {
+					t.Errorf("request %d Content-Type = %q", i, ct)
+				}
+			}
+			for i, m := range msgList {
+				want := outputstest.Finished
+				if i == 1 {
+					want = outputstest.DeadLettered
+				}
+				if got := d.Response(m); got != want {
+					t.Errorf("message %d response = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishSignature(t *testing.T) {
+	s := newServer(t, always(http.StatusOK))
+	c := newClient(t, &Config{
+		URL:             s.URL,
+		Batch:           true,
+		Secret:          "secret",
+		SignatureHeader: "X-Signature",
+		Headers:         map[string]string{"X-Source": "nsq"},
+	})
+	d := outputstest.NewDelegate()
+
+	c.Publish(d.Messages("test", `{"a":1}`, `{"b":2}`))
+
+	bodies, headers := s.requests()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(bodies))
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(bodies[0]))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := headers[0].Get("X-Signature"); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+	if got := headers[0].Get("X-Source"); got != "nsq" {
+		t.Errorf("X-Source = %q, want nsq", got)
+	}
+}
+
+func TestPublishNoSignature(t *testing.T) {
+	s := newServer(t, always(http.StatusOK))
+	c := newClient(t, &Config{URL: s.URL, SignatureHeader: "X-Signature"})
+	d := outputstest.NewDelegate()
+
+	c.Publish(d.Messages("test", `{"a":1}`))
+
+	_, headers := s.requests()
+	if len(headers) != 1 {
+		t.Fatalf("got %d requests, want 1", len(headers))
+	}
+	if got := headers[0].Get("X-Signature"); got != "" {
+		t.Errorf("X-Signature = %q, want none without a secret", got)
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		requests int
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, requests: 3},
+		{name: "too many requests", status: http.StatusTooManyRequests, requests: 3},
+		{name: "client error", status: http.StatusBadRequest, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t, always(tt.status))
+			c := newClient(t, &Config{URL: s.URL, Batch: true, MaxRetries: 2})
+			d := outputstest.NewDelegate()
+			msgList := d.Messages("test", `{"a":1}`, `{"b":2}`)
+
+			c.Publish(msgList)
+
+			if bodies, _ := s.requests(); len(bodies) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(bodies), tt.requests)
+			}
+			for i, m := range msgList {
+				if got := d.Response(m); got != outputstest.Requeued {
+					t.Errorf("message %d response = %d, want requeued", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishRetrySuccess(t *testing.T) {
+	s := newServer(t, func(n int) int {
+		if n == 0 {
+			return http.StatusBadGateway
+		}
+		return http.StatusNoContent
+	})
+	c := newClient(t, &Config{URL: s.URL, MaxRetries: 2})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"a":1}`)
+
+	c.Publish(msgList)
+
+	if bodies, _ := s.requests(); len(bodies) != 2 {
+		t.Errorf("got %d requests, want 2", len(bodies))
+	}
+	if got := d.Response(msgList[0]); got != outputstest.Finished {
+		t.Errorf("response = %d, want finished", got)
+	}
+}
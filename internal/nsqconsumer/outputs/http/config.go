@@ -0,0 +1,14 @@
+package http
+
+type Config struct {
+	URL             string            `config:"url" json:"url"`
+	Method          string            `config:"method" json:"method"`
+	Headers         map[string]string `config:"headers" json:"headers"`
+	Batch           bool              `config:"batch" json:"batch"`
+	BatchSize       int               `config:"batch_size" json:"batch_size"`
+	Secret          string            `config:"secret" json:"secret"`
+	SignatureHeader string            `config:"signature_header" json:"signature_header"`
+	Timeout         int               `config:"timeout" json:"timeout"`
+	MaxRetries      int               `config:"max_retries" json:"max_retries"`
+	RetryBackoff    int               `config:"retry_backoff" json:"retry_backoff"`
+}
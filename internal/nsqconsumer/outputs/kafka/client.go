@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+var compressions = map[string]kafka.Compression{
+	"gzip":   kafka.Gzip,
+	"snappy": kafka.Snappy,
+	"lz4":    kafka.Lz4,
+	"zstd":   kafka.Zstd,
+}
+
+// Client produces messages to kafka.
+type Client struct {
+	config *Config
+	writer *kafka.Writer
+
+	// transport sends the writer requests, the kafka default when nil.
+	transport kafka.RoundTripper
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config: config,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %v", c.config.Brokers)
+	conn, err := kafka.DialContext(context.Background(), "tcp", c.config.Brokers[0])
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	timeout := time.Duration(c.config.Timeout) * time.Second
+	c.writer = &kafka.Writer{
+		Addr:         kafka.TCP(c.config.Brokers...),
+		Transport:    c.transport,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  c.config.MaxAttempts,
+		BatchSize:    c.config.BatchSize,
+		BatchBytes:   c.config.BatchBytes,
+		BatchTimeout: 10 * time.Millisecond,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		RequiredAcks: kafka.RequiredAcks(c.config.Acks),
+		Compression:  compressions[c.config.Compression],
+		ErrorLogger:  kafka.LoggerFunc(log.Errorf),
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	return c.writer.Close()
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("kafka %v publish", c.config.Brokers)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("kafka %v close", c.config.Brokers)
+}
+
+func (c *Client) topic(topic string) string {
+	if t, ok := c.config.Topics[topic]; ok {
+		return t
+	}
+	return topic
+}
+
+// key returns the partition key of a message body, nil when no key field is
+// configured or the field is missing.
+func (c *Client) key(body []byte) ([]byte, error) {
+	if c.config.KeyField == "" {
+		return nil, nil
+	}
+
+	event := make(map[string]interface{})
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	v, ok := message.Lookup(event, c.config.KeyField)
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+	return []byte(fmt.Sprint(v)), nil
+}
+
+// Publish produces msgList and finishes the messages kafka acknowledged,
+// requeueing the others.
+func (c *Client) Publish(msgList []*message.Message) {
+	pending := make([]*message.Message, 0, len(msgList))
+	records := make([]kafka.Message, 0, len(msgList))
+	for _, m := range msgList {
+		key, err := c.key(m.GetData().Body)
+		if err != nil {
+			log.Infof("Unmarshal nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("unmarshal fail: %v", err))
+			continue
+		}
+		records = append(records, kafka.Message{
+			Topic: c.topic(m.GetTopic()),
+			Key:   key,
+			Value: m.GetData().Body,
+		})
+		pending = append(pending, m)
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	err := c.writer.WriteMessages(context.Background(), records...)
+	// WriteErrors reports the status of each message, any other error fails
+	// the whole batch.
+	var writeErrs kafka.WriteErrors
+	perMessage := errors.As(err, &writeErrs) && len(writeErrs) == len(pending)
+	failed := 0
+	for i, m := range pending {
+		ok := err == nil
+		if perMessage {
+			ok = writeErrs[i] == nil
+		}
+		if ok {
+			m.GetData().Finish()
+			continue
+		}
+		m.Requeue()
+		failed++
+	}
+	if err != nil {
+		log.Errorf("Produce %d of %d messages to kafka fail: %v", failed, len(pending), err)
+	}
+}
@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+const testPartitions = 2
+
+// record is a message produced to the fake broker.
+type record struct {
+	topic string
+	key   string
+	value string
+}
+
+// transport is a fake broker serving every topic with testPartitions
+// partitions. It records the produced messages and fails the produce
+// requests of the partitions in fail, or every request when err is set.
+type transport struct {
+	mu      sync.Mutex
+	records []record
+	fail    map[int32]bool
+	err     error
+}
+
+func (tr *transport) RoundTrip(ctx context.Context, addr net.Addr, req kafka.Request) (protocol.Message, error) {
+	if tr.err != nil {
+		return nil, tr.err
+	}
+
+	switch req := req.(type) {
+	case *metadata.Request:
+		res := &metadata.Response{}
+		for _, name := range req.TopicNames {
+			topic := metadata.ResponseTopic{Name: name}
+			for i := int32(0); i < testPartitions; i++ {
+				topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{PartitionIndex: i})
+			}
+			res.Topics = append(res.Topics, topic)
+		}
+		return res, nil
+	case *produce.Request:
+		res := &produce.Response{}
+		for _, t := range req.Topics {
+			topic := produce.ResponseTopic{Topic: t.Topic}
+			for _, p := range t.Partitions {
+				partition := produce.ResponsePartition{Partition: p.Partition}
+				if tr.fail[p.Partition] {
+					partition.ErrorCode = int16(kafka.MessageSizeTooLarge)
+				} else if err := tr.record(t.Topic, p.RecordSet.Records); err != nil {
+					return nil, err
+				}
+				topic.Partitions = append(topic.Partitions, partition)
+			}
+			res.Topics = append(res.Topics, topic)
+		}
+		return res, nil
+	}
+	return nil, errors.New("unexpected request")
+}
+
+func (tr *transport) record(topic string, records protocol.RecordReader) error {
+	for {
+		r, err := records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := protocol.ReadAll(r.Key)
+		if err != nil {
+			return err
+		}
+		value, err := protocol.ReadAll(r.Value)
+		if err != nil {
+			return err
+		}
+		tr.mu.Lock()
+		tr.records = append(tr.records, record{topic: topic, key: string(key), value: string(value)})
+		tr.mu.Unlock()
+	}
+}
+
+// produced returns the recorded messages by value.
+func (tr *transport) produced() map[string]record {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	produced := make(map[string]record, len(tr.records))
+	for _, r := range tr.records {
+		produced[r.value] = r
+	}
+	return produced
+}
+
+// newBroker listens for the connection Connect checks the broker with.
+func newBroker(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func newClient(t *testing.T, tr *transport, config *Config) *Client {
+	config.Brokers = []string{newBroker(t)}
+	config.Acks = int(kafka.RequireAll)
+	config.MaxAttempts = 1
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.transport = tr
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// partition returns the partition the writer hashes key to.
+func partition(key string) int32 {
+	partitions := make([]int, testPartitions)
+	for i := range partitions {
+		partitions[i] = i
+	}
+	return int32((&kafka.Hash{}).Balance(kafka.Message{Key: []byte(key)}, partitions...))
+}
+
+func TestConnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	c, err := NewClient(&Config{Brokers: []string{addr}})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err == nil {
+		t.Error("Connect succeeded, want an error for an unreachable broker")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	tr := &transport{}
+	c := newClient(t, tr, &Config{
+		Topics:   map[string]string{"test": "events"},
+		KeyField: "user.id",
+	})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := append(
+		d.Messages("test", `{"user": {"id": "a"}}`, `not json`, `{"user": {"id": 7}}`, `{"other": 1}`),
+		d.Messages("orders", `{"user": {"id": "b"}}`)...,
+	)
+
+	c.Publish(msgList)
+
+	want := map[string]record{
+		`{"user": {"id": "a"}}`: {topic: "events", key: "a"},
+		`{"user": {"id": 7}}`:   {topic: "events", key: "7"},
+		`{"other": 1}`:          {topic: "events"},
+		`{"user": {"id": "b"}}`: {topic: "orders", key: "b"},
+	}
+	produced := tr.produced()
+	if len(produced) != len(want) {
+		t.Errorf("produced %d messages, want %d", len(produced), len(want))
+	}
+	for value, w := range want {
+		got, ok := produced[value]
+		if !ok {
+			t.Errorf("message %s not produced", value)
+			continue
+		}
+		if got.topic != w.topic || got.key != w.key {
+			t.Errorf("message %s produced to %s with key %q, want %s with key %q", value, got.topic, got.key, w.topic, w.key)
+		}
+	}
+	for i, m := range msgList {
+		want := outputstest.Finished
+		if i == 1 {
+			want = outputstest.DeadLettered
+		}
+		if got := d.Response(m); got != want {
+			t.Errorf("message %d response = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestPublishPartialFailure(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	failed := partition(keys[0])
+	tr := &transport{fail: map[int32]bool{failed: true}}
+	c := newClient(t, tr, &Config{KeyField: "id"})
+	d := outputstest.NewDelegate()
+	bodies := make([]string, 0, len(keys))
+	for _, key := range keys {
+		bodies = append(bodies, `{"id": "`+key+`"}`)
+	}
+	msgList := d.Messages("test", bodies...)
+
+	c.Publish(msgList)
+
+	produced := tr.produced()
+	for i, m := range msgList {
+		want := outputstest.Finished
+		if partition(keys[i]) == failed {
+			want = outputstest.Requeued
+		}
+		if got := d.Response(m); got != want {
+			t.Errorf("message %s response = %d, want %d", keys[i], got, want)
+		}
+		if _, ok := produced[bodies[i]]; ok != (want == outputstest.Finished) {
+			t.Errorf("message %s produced = %v, want %v", keys[i], ok, !ok)
+		}
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	tr := &transport{err: errors.New("broker down")}
+	c := newClient(t, tr, &Config{})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"a": 1}`, `{"b": 2}`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package kafka
+
+type Config struct {
+	Brokers     []string          `config:"brokers" json:"brokers"`
+	Topics      map[string]string `config:"topics" json:"topics"`
+	KeyField    string            `config:"key_field" json:"key_field"`
+	Acks        int               `config:"acks" json:"acks"`
+	Compression string            `config:"compression" json:"compression"`
+	BatchSize   int               `config:"batch_size" json:"batch_size"`
+	BatchBytes  int64             `config:"batch_bytes" json:"batch_bytes"`
+	MaxAttempts int               `config:"max_attempts" json:"max_attempts"`
+	Timeout     int               `config:"timeout" json:"timeout"`
+}
@@ -0,0 +1,218 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+const pushPath = "/loki/api/v1/push"
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type entry struct {
+	ts   time.Time
+	line string
+}
+
+// Client pushes messages to loki, grouped into streams by labels.
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config: config,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s", c.config.URL)
+	c.client = &http.Client{
+		Timeout: time.Duration(c.config.Timeout) * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("loki %s publish", c.config.URL)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("loki %s close", c.config.URL)
+}
+
+// labels returns the stream labels of an event.
+func (c *Client) labels(topic string, event map[string]interface{}) map[string]string {
+	labels := map[string]string{"topic": topic}
+	for name, path := range c.config.Labels {
+		if v, ok := message.Lookup(event, path); ok && v != nil {
+			labels[name] = fmt.Sprint(v)
+		}
+	}
+	return labels
+}
+
+// streamKey returns a stable identifier of a label set.
+func streamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[name]))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	pending := make([]*message.Message, 0, len(msgList))
+	keys := make([]string, 0)
+	labels := make(map[string]map[string]string)
+	entries := make(map[string][]entry)
+	for _, m := range msgList {
+		data := m.GetData()
+		event := make(map[string]interface{})
+		var line bytes.Buffer
+		if err := json.Unmarshal(data.Body, &event); err != nil {
+			log.Infof("Unmarshal nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("unmarshal fail: %v", err))
+			continue
+		}
+		if err := json.Compact(&line, data.Body); err != nil {
+			log.Infof("Compact nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("compact fail: %v", err))
+			continue
+		}
+
+		ts := time.Unix(0, data.Timestamp)
+		if v, ok := message.Lookup(event, c.config.TimestampField); ok {
+			if t, ok := parseTime(v); ok {
+				ts = t
+			}
+		}
+
+		l := c.labels(m.GetTopic(), event)
+		key := streamKey(l)
+		if _, ok := labels[key]; !ok {
+			keys = append(keys, key)
+			labels[key] = l
+		}
+		entries[key] = append(entries[key], entry{ts: ts, line: line.String()})
+		pending = append(pending, m)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	streams := make([]stream, 0, len(keys))
+	for _, key := range keys {
+		list := entries[key]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ts.Before(list[j].ts) })
+		values := make([][2]string, 0, len(list))
+		for _, e := range list {
+			values = append(values, [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line})
+		}
+		streams = append(streams, stream{Stream: labels[key], Values: values})
+	}
+
+	err := c.push(streams)
+	for _, m := range pending {
+		if err != nil {
+			m.Requeue()
+		} else {
+			m.GetData().Finish()
+		}
+	}
+	if err != nil {
+		log.Errorf("Push %d entries to loki fail: %v", len(pending), err)
+	}
+}
+
+func (c *Client) push(streams []stream) error {
+	body, err := json.Marshal(map[string]interface{}{"streams": streams})
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimRight(c.config.URL, "/") + pushPath
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.config.TenantID)
+	}
+	if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// parseTime converts an RFC3339 string or a unix timestamp in seconds,
+// milliseconds, microseconds or nanoseconds into a time.
+func parseTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return ts, true
+	case float64:
+		switch {
+		case t < 1e11:
+			return time.Unix(0, int64(t*float64(time.Second))), true
+		case t < 1e14:
+			return time.Unix(0, int64(t*float64(time.Millisecond))), true
+		case t < 1e17:
+			return time.Unix(0, int64(t*float64(time.Microsecond))), true
+		default:
+			return time.Unix(0, int64(t)), true
+		}
+	}
+	return time.Time{}, false
+}
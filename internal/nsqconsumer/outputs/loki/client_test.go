@@ -0,0 +1,208 @@
+package loki
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+// server records the pushes it receives and responds with status.
+type server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	pushes  []pushRequest
+	headers []http.Header
+}
+
+func newServer(t *testing.T, status int) *server {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != pushPath {
+			t.Errorf("path = %s, want %s", r.URL.Path, pushPath)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		var push pushRequest
+		if err := json.Unmarshal(body, &push); err != nil {
+			t.Errorf("decode push %s: %v", body, err)
+		}
+		s.mu.Lock()
+		s.pushes = append(s.pushes, push)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// requests returns the pushes and headers of the received requests.
+func (s *server) requests() ([]pushRequest, []http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pushes, s.headers
+}
+
+func newClient(t *testing.T, config *Config) *Client {
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestPublishStreams(t *testing.T) {
+	s := newServer(t, http.StatusNoContent)
+	c := newClient(t, &Config{
+		URL:            s.URL + "/",
+		Labels:         map[string]string{"level": "level", "app": "meta.app"},
+		TimestampField: "ts",
+	})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("test",
+		`{"level": "info", "meta": {"app": "a"}, "ts": 3}`,
+		`{"level": "error", "ts": 2}`,
+		`not json`,
+		`{"level": "info", "meta": {"app": "a"}, "ts": 1}`,
+	)
+
+	c.Publish(msgList)
+
+	pushes, _ := s.requests()
+	if len(pushes) != 1 {
+		t.Fatalf("got %d pushes, want 1", len(pushes))
+	}
+	want := []stream{
+		{
+			Stream: map[string]string{"topic": "test", "level": "info", "app": "a"},
+			Values: [][2]string{
+				{"1000000000", `{"level":"info","meta":{"app":"a"},"ts":1}`},
+				{"3000000000", `{"level":"info","meta":{"app":"a"},"ts":3}`},
+			},
+		},
+		{
+			Stream: map[string]string{"topic": "test", "level": "error"},
+			Values: [][2]string{
+				{"2000000000", `{"level":"error","ts":2}`},
+			},
+		},
+	}
+	if got := pushes[0].Streams; !reflect.DeepEqual(got, want) {
+		t.Errorf("streams = %v, want %v", got, want)
+	}
+	for i, m := range msgList {
+		want := outputstest.Finished
+		if i == 2 {
+			want = outputstest.DeadLettered
+		}
+		if got := d.Response(m); got != want {
+			t.Errorf("message %d response = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestPublishHeaders(t *testing.T) {
+	s := newServer(t, http.StatusNoContent)
+	c := newClient(t, &Config{
+		URL:      s.URL,
+		TenantID: "tenant",
+		Username: "user",
+		Password: "pass",
+	})
+	d := outputstest.NewDelegate()
+
+	c.Publish(d.Messages("test", `{"a":1}`))
+
+	_, headers := s.requests()
+	if len(headers) != 1 {
+		t.Fatalf("got %d pushes, want 1", len(headers))
+	}
+	if got := headers[0].Get("X-Scope-OrgID"); got != "tenant" {
+		t.Errorf("X-Scope-OrgID = %q, want tenant", got)
+	}
+	if got := headers[0].Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	req := &http.Request{Header: headers[0]}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("basic auth = %q, %q, %v, want user, pass", user, pass, ok)
+	}
+}
+
+func TestPublishTimestamp(t *testing.T) {
+	s := newServer(t, http.StatusNoContent)
+	c := newClient(t, &Config{URL: s.URL, TimestampField: "ts"})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"ts": "bad"}`)
+
+	c.Publish(msgList)
+
+	pushes, _ := s.requests()
+	if len(pushes) != 1 || len(pushes[0].Streams) != 1 || len(pushes[0].Streams[0].Values) != 1 {
+		t.Fatalf("pushes = %v, want a single entry", pushes)
+	}
+	ts := msgList[0].GetData().Timestamp
+	if got, want := pushes[0].Streams[0].Values[0][0], strconv.FormatInt(ts, 10); got != want {
+		t.Errorf("timestamp = %s, want the nsq timestamp %s", got, want)
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	s := newServer(t, http.StatusBadRequest)
+	c := newClient(t, &Config{URL: s.URL})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"a":1}`, `{"b":2}`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want int64
+		ok   bool
+	}{
+		{v: "2021-01-02T03:04:05.5Z", want: 1609556645500000000, ok: true},
+		{v: float64(1609556645), want: 1609556645000000000, ok: true},
+		{v: float64(1609556645500), want: 1609556645500000000, ok: true},
+		{v: float64(1609556645500000), want: 1609556645500000000, ok: true},
+		{v: float64(1609556645500000000), want: 1609556645500000000, ok: true},
+		{v: "yesterday"},
+		{v: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseTime(tt.v)
+		if ok != tt.ok {
+			t.Errorf("parseTime(%v) ok = %v, want %v", tt.v, ok, tt.ok)
+			continue
+		}
+		if ok && got.UnixNano() != tt.want {
+			t.Errorf("parseTime(%v) = %d, want %d", tt.v, got.UnixNano(), tt.want)
+		}
+	}
+}
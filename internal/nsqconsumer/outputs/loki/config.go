@@ -0,0 +1,12 @@
+package loki
+
+type Config struct {
+	URL            string            `config:"url" json:"url"`
+	TenantID       string            `config:"tenant_id" json:"tenant_id"`
+	Username       string            `config:"username" json:"username"`
+	Password       string            `config:"password" json:"password"`
+	Labels         map[string]string `config:"labels" json:"labels"`
+	TimestampField string            `config:"timestamp_field" json:"timestamp_field"`
+	BatchSize      int               `config:"batch_size" json:"batch_size"`
+	Timeout        int               `config:"timeout" json:"timeout"`
+}
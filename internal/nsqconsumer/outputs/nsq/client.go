@@ -0,0 +1,117 @@
+package nsq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+// Client republishes messages to a nsqd.
+type Client struct {
+	config   *Config
+	topic    *outputs.Template
+	producer *nsq.Producer
+}
+
+func NewClient(config *Config) (*Client, error) {
+	topic, err := outputs.NewTemplate("topic", config.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("parse topic template fail: %w", err)
+	}
+
+	c := &Client{
+		config: config,
+		topic:  topic,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s", c.config.NsqdTCPAddress)
+	nsqConfig := nsq.NewConfig()
+	nsqConfig.UserAgent = fmt.Sprintf("nsq-tool-kit/%s go-nsq/%s", "0.0.1", nsq.VERSION)
+	producer, err := nsq.NewProducer(c.config.NsqdTCPAddress, nsqConfig)
+	if err != nil {
+		return err
+	}
+	producer.SetLogger(log.StdInfoLogger(), nsq.LogLevelInfo)
+	if err := producer.Ping(); err != nil {
+		producer.Stop()
+		return err
+	}
+
+	c.producer = producer
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	c.producer.Stop()
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("nsq %s publish", c.config.NsqdTCPAddress)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("nsq %s close", c.config.NsqdTCPAddress)
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	topics := make([]string, 0)
+	groups := make(map[string][]*message.Message)
+	for _, m := range msgList {
+		topic, err := c.topic.Render(m)
+		if err != nil {
+			log.Infof("Render topic of nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("render topic fail: %v", err))
+			continue
+		}
+		if !nsq.IsValidTopicName(topic) {
+			log.Infof("Render topic of nsq message fail: invalid topic %q", topic)
+			m.DeadLetter(fmt.Sprintf("invalid topic %q", topic))
+			continue
+		}
+		if _, ok := groups[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		groups[topic] = append(groups[topic], m)
+	}
+
+	for _, topic := range topics {
+		group := groups[topic]
+		err := c.publish(topic, group)
+		for _, m := range group {
+			if err != nil {
+				m.Requeue()
+			} else {
+				m.GetData().Finish()
+			}
+		}
+		if err != nil {
+			log.Errorf("Publish %d messages to %s fail: %v", len(group), topic, err)
+		}
+	}
+}
+
+func (c *Client) publish(topic string, msgList []*message.Message) error {
+	if c.config.Defer > 0 {
+		delay := time.Duration(c.config.Defer) * time.Millisecond
+		for _, m := range msgList {
+			if err := c.producer.DeferredPublish(topic, delay, m.GetData().Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	body := make([][]byte, 0, len(msgList))
+	for _, m := range msgList {
+		body = append(body, m.GetData().Body)
+	}
+	return c.producer.MultiPublish(topic, body)
+}
@@ -0,0 +1,242 @@
+package nsq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// command is a publish command received by the fake nsqd.
+type command struct {
+	name   string
+	topic  string
+	delay  string
+	bodies []string
+}
+
+// nsqd is a fake nsqd answering the publish commands of a producer.
+type nsqd struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	commands []command
+	fail     bool
+}
+
+func newNsqd(t *testing.T) *nsqd {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	s := &nsqd{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *nsqd) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *nsqd) received() []command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]command(nil), s.commands...)
+}
+
+func (s *nsqd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		params := strings.Fields(line)
+		if len(params) == 0 {
+			return
+		}
+		cmd := command{name: params[0]}
+		if len(params) > 1 {
+			cmd.topic = params[1]
+		}
+		switch cmd.name {
+		case "NOP":
+			continue
+		case "IDENTIFY":
+			if _, err := readBody(r); err != nil {
+				return
+			}
+			respond(conn, 0, "OK")
+			continue
+		case "PUB", "DPUB":
+			body, err := readBody(r)
+			if err != nil {
+				return
+			}
+			if cmd.name == "DPUB" {
+				cmd.delay = params[2]
+			}
+			cmd.bodies = []string{string(body)}
+		case "MPUB":
+			if _, err := readSize(r); err != nil {
+				return
+			}
+			n, err := readSize(r)
+			if err != nil {
+				return
+			}
+			for i := 0; i < int(n); i++ {
+				body, err := readBody(r)
+				if err != nil {
+					return
+				}
+				cmd.bodies = append(cmd.bodies, string(body))
+			}
+		default:
+			return
+		}
+
+		s.mu.Lock()
+		fail := s.fail
+		if !fail {
+			s.commands = append(s.commands, cmd)
+		}
+		s.mu.Unlock()
+		if fail {
+			respond(conn, 1, "E_PUB_FAILED publish failed")
+		} else {
+			respond(conn, 0, "OK")
+		}
+	}
+}
+
+func readSize(r io.Reader) (uint32, error) {
+	var size uint32
+	err := binary.Read(r, binary.BigEndian, &size)
+	return size, err
+}
+
+func readBody(r io.Reader) ([]byte, error) {
+	size, err := readSize(r)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, size)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+// respond writes a response or error frame.
+func respond(w io.Writer, frameType int32, data string) {
+	buf := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(4+len(data)))
+	binary.BigEndian.PutUint32(buf[4:], uint32(frameType))
+	_, _ = w.Write(append(buf, data...))
+}
+
+func newClient(t *testing.T, s *nsqd, config *Config) *Client {
+	t.Helper()
+	config.NsqdTCPAddress = s.ln.Addr().String()
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPublish(t *testing.T) {
+	s := newNsqd(t)
+	c := newClient(t, s, &Config{Topic: "{{.Topic}}_{{.Fields.service}}"})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("logs",
+		`{"service":"api","n":1}`,
+		`{"service":"web","n":2}`,
+		`{"service":"api","n":3}`,
+		`{"n":4}`,
+		`{"service":"a b"}`,
+	)
+
+	c.Publish(msgList)
+
+	want := []command{
+		{name: "MPUB", topic: "logs_api", bodies: []string{`{"service":"api","n":1}`, `{"service":"api","n":3}`}},
+		{name: "MPUB", topic: "logs_web", bodies: []string{`{"service":"web","n":2}`}},
+	}
+	if got := s.received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %+v, want %+v", got, want)
+	}
+	responses := []int{
+		outputstest.Finished, outputstest.Finished, outputstest.Finished,
+		outputstest.DeadLettered, outputstest.DeadLettered,
+	}
+	for i, m := range msgList {
+		if got := d.Response(m); got != responses[i] {
+			t.Errorf("message %d response = %d, want %d", i, got, responses[i])
+		}
+	}
+}
+
+func TestPublishDefer(t *testing.T) {
+	s := newNsqd(t)
+	c := newClient(t, s, &Config{Topic: "delayed", Defer: 1500})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(msgList)
+
+	want := []command{
+		{name: "DPUB", topic: "delayed", delay: "1500", bodies: []string{`{"n":1}`}},
+		{name: "DPUB", topic: "delayed", delay: "1500", bodies: []string{`{"n":2}`}},
+	}
+	if got := s.received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %+v, want %+v", got, want)
+	}
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Finished {
+			t.Errorf("message %d response = %d, want finished", i, got)
+		}
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	s := newNsqd(t)
+	c := newClient(t, s, &Config{Topic: "copy"})
+	d := outputstest.NewDelegate()
+	s.setFail(true)
+	msgList := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(msgList)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package nsq
+
+type Config struct {
+	NsqdTCPAddress string `config:"nsqd_tcp_address" json:"nsqd_tcp_address"`
+	Topic          string `config:"topic" json:"topic"`
+	Defer          int    `config:"defer" json:"defer"`
+	BatchSize      int    `config:"batch_size" json:"batch_size"`
+}
@@ -1,9 +1,18 @@
 package outputs
 
+import (
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Client defines the interface every output must implement.
 type Client interface {
 	Close() error
 
 	Connect() error
 
-	Publish() error
+	// Run consumes messages from msgChan until it is closed.
+	Run(msgChan <-chan *message.Message)
+
+	// Publish sends a batch of messages, finishing or requeueing each of them.
+	Publish(msgList []*message.Message)
 }
@@ -0,0 +1,92 @@
+// Package outputstest provides helpers for testing the outputs.
+package outputstest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Responses of a message.
+const (
+	None = iota
+	Finished
+	Requeued
+	DeadLettered
+)
+
+// Delegate records how the messages of a test are responded to.
+type Delegate struct {
+	mu        sync.Mutex
+	responses map[nsq.MessageID]int
+}
+
+func NewDelegate() *Delegate {
+	return &Delegate{
+		responses: make(map[nsq.MessageID]int),
+	}
+}
+
+func (d *Delegate) OnFinish(m *nsq.Message) {
+	d.set(m, Finished)
+}
+
+func (d *Delegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	d.set(m, Requeued)
+}
+
+func (d *Delegate) OnTouch(m *nsq.Message) {}
+
+func (d *Delegate) set(m *nsq.Message, response int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.responses[m.ID] = response
+}
+
+// Requeue requeues m, the Delegate is the message.Requeuer of the test after
+// SetRequeuer.
+func (d *Delegate) Requeue(m *message.Message) {
+	m.GetData().Requeue(-1)
+}
+
+// DeadLetter records m as dead-lettered.
+func (d *Delegate) DeadLetter(m *message.Message, reason string) {
+	d.set(m.GetData(), DeadLettered)
+}
+
+// SetRequeuer makes d the message.Requeuer until the end of the test, so the
+// dead-lettered messages can be told from the finished ones.
+func (d *Delegate) SetRequeuer(t testing.TB) {
+	message.SetRequeuer(d)
+	t.Cleanup(func() { message.SetRequeuer(nil) })
+}
+
+// Response returns how m was responded to.
+func (d *Delegate) Response(m *message.Message) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.responses[m.GetData().ID]
+}
+
+// Messages returns a message of topic for each body, responding to d.
+func (d *Delegate) Messages(topic string, bodies ...string) []*message.Message {
+	msgList := make([]*message.Message, 0, len(bodies))
+	for _, body := range bodies {
+		d.mu.Lock()
+		var id nsq.MessageID
+		copy(id[:], strconv.Itoa(len(d.responses)))
+		d.responses[id] = None
+		d.mu.Unlock()
+
+		data := nsq.NewMessage(id, []byte(body))
+		data.Delegate = d
+		data.Attempts = 1
+		msgList = append(msgList, message.NewMessage(data, topic))
+	}
+	return msgList
+}
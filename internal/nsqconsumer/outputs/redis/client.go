@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+const (
+	modeStream = "stream"
+	modeList   = "list"
+	modePubSub = "pubsub"
+)
+
+// Client writes messages to redis streams, lists or channels.
+type Client struct {
+	config *Config
+	key    *outputs.Template
+	client *redis.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	switch config.Mode {
+	case modeStream, modeList, modePubSub:
+	default:
+		return nil, fmt.Errorf("unsupported redis mode %q", config.Mode)
+	}
+	key, err := outputs.NewTemplate("key", config.Key)
+	if err != nil {
+		return nil, fmt.Errorf("parse key template fail: %w", err)
+	}
+
+	c := &Client{
+		config: config,
+		key:    key,
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s", c.config.Addr)
+	client := redis.NewClient(&redis.Options{
+		Addr:     c.config.Addr,
+		Username: c.config.Username,
+		Password: c.config.Password,
+		DB:       c.config.DB,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return err
+	}
+
+	c.client = client
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	return c.client.Close()
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("redis %s publish", c.config.Addr)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("redis %s close", c.config.Addr)
+}
+
+func (c *Client) Publish(msgList []*message.Message) {
+	ctx := context.Background()
+	pending := make([]*message.Message, 0, len(msgList))
+	cmds := make([]redis.Cmder, 0, len(msgList))
+	pipe := c.client.Pipeline()
+	for _, m := range msgList {
+		key, err := c.key.Render(m)
+		if err != nil {
+			log.Infof("Render key of nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("render key fail: %v", err))
+			continue
+		}
+		if key == "" {
+			log.Info("Render key of nsq message fail: empty key")
+			m.DeadLetter("empty key")
+			continue
+		}
+
+		body := m.GetData().Body
+		switch c.config.Mode {
+		case modeStream:
+			cmds = append(cmds, pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: key,
+				MaxLen: c.config.MaxLen,
+				Approx: true,
+				Values: []interface{}{c.config.StreamField, body},
+			}))
+		case modeList:
+			cmds = append(cmds, pipe.LPush(ctx, key, body))
+		case modePubSub:
+			cmds = append(cmds, pipe.Publish(ctx, key, body))
+		}
+		pending = append(pending, m)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	// Exec returns the first failed command, each command is checked below.
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("Exec redis pipeline fail: %v", err)
+	}
+	for i, m := range pending {
+		if err := cmds[i].Err(); err != nil {
+			m.Requeue()
+		} else {
+			m.GetData().Finish()
+		}
+	}
+}
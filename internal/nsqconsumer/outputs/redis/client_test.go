@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func newClient(t *testing.T, s *miniredis.Miniredis, config *Config) *Client {
+	config.Addr = s.Addr()
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func checkResponses(t *testing.T, d *outputstest.Delegate, msgList []*message.Message, want ...int) {
+	t.Helper()
+	for i, m := range msgList {
+		if got := d.Response(m); got != want[i] {
+			t.Errorf("message %d response = %d, want %d", i, got, want[i])
+		}
+	}
+}
+
+func TestPublishStream(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := newClient(t, s, &Config{
+		Mode:        modeStream,
+		Key:         "{{.Topic}}:{{.Fields.service}}",
+		StreamField: "body",
+		MaxLen:      2,
+	})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("logs",
+		`{"service":"api","n":1}`,
+		`{"service":"web","n":2}`,
+		`{"n":3}`,
+		`{"service":"api","n":4}`,
+		`{"service":"api","n":5}`,
+	)
+
+	c.Publish(msgList)
+
+	checkResponses(t, d, msgList,
+		outputstest.Finished, outputstest.Finished, outputstest.DeadLettered,
+		outputstest.Finished, outputstest.Finished)
+	tests := []struct {
+		key    string
+		bodies []string
+	}{
+		{key: "logs:api", bodies: []string{`{"service":"api","n":4}`, `{"service":"api","n":5}`}},
+		{key: "logs:web", bodies: []string{`{"service":"web","n":2}`}},
+	}
+	for _, tt := range tests {
+		entries, err := s.Stream(tt.key)
+		if err != nil {
+			t.Fatalf("Stream(%s): %v", tt.key, err)
+		}
+		var bodies []string
+		for _, e := range entries {
+			if len(e.Values) != 2 || e.Values[0] != "body" {
+				t.Errorf("%s entry values = %v, want a body field", tt.key, e.Values)
+				continue
+			}
+			bodies = append(bodies, e.Values[1])
+		}
+		if !reflect.DeepEqual(bodies, tt.bodies) {
+			t.Errorf("%s bodies = %v, want %v", tt.key, bodies, tt.bodies)
+		}
+	}
+	if keys := s.Keys(); len(keys) != 2 {
+		t.Errorf("keys = %v, want the two streams", keys)
+	}
+}
+
+func TestPublishList(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := newClient(t, s, &Config{Mode: modeList, Key: "queue:{{.Topic}}"})
+	d := outputstest.NewDelegate()
+	msgList := append(d.Messages("a", `{"n":1}`, `{"n":2}`), d.Messages("b", `{"n":3}`)...)
+
+	c.Publish(msgList)
+
+	checkResponses(t, d, msgList, outputstest.Finished, outputstest.Finished, outputstest.Finished)
+	tests := []struct {
+		key    string
+		bodies []string
+	}{
+		{key: "queue:a", bodies: []string{`{"n":2}`, `{"n":1}`}},
+		{key: "queue:b", bodies: []string{`{"n":3}`}},
+	}
+	for _, tt := range tests {
+		bodies, err := s.List(tt.key)
+		if err != nil {
+			t.Fatalf("List(%s): %v", tt.key, err)
+		}
+		if !reflect.DeepEqual(bodies, tt.bodies) {
+			t.Errorf("%s = %v, want %v", tt.key, bodies, tt.bodies)
+		}
+	}
+}
+
+func TestPublishPubSub(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+	subscriber := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer subscriber.Close()
+	sub := subscriber.Subscribe(ctx, "events.logs")
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	c := newClient(t, s, &Config{Mode: modePubSub, Key: "events.{{.Topic}}"})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(msgList)
+
+	checkResponses(t, d, msgList, outputstest.Finished, outputstest.Finished)
+	for _, want := range []string{`{"n":1}`, `{"n":2}`} {
+		select {
+		case m := <-sub.Channel():
+			if m.Channel != "events.logs" || m.Payload != want {
+				t.Errorf("got %s on %s, want %s on events.logs", m.Payload, m.Channel, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+}
+
+func TestPublishDeadLetterEmptyKey(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := newClient(t, s, &Config{Mode: modeList, Key: "{{.Fields.queue}}"})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	msgList := d.Messages("logs", `{"queue":""}`, `{"queue":"q"}`)
+
+	c.Publish(msgList)
+
+	checkResponses(t, d, msgList, outputstest.DeadLettered, outputstest.Finished)
+}
+
+func TestPublishRequeue(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := newClient(t, s, &Config{Mode: modeList, Key: "{{.Topic}}"})
+	s.SetError("LOADING redis is loading the dataset in memory")
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("logs", `{"n":1}`, `{"n":2}`)
+
+	c.Publish(msgList)
+
+	checkResponses(t, d, msgList, outputstest.Requeued, outputstest.Requeued)
+}
@@ -0,0 +1,13 @@
+package redis
+
+type Config struct {
+	Addr        string `config:"addr" json:"addr"`
+	Username    string `config:"username" json:"username"`
+	Password    string `config:"password" json:"password"`
+	DB          int    `config:"db" json:"db"`
+	Mode        string `config:"mode" json:"mode"`
+	Key         string `config:"key" json:"key"`
+	StreamField string `config:"stream_field" json:"stream_field"`
+	MaxLen      int64  `config:"max_len" json:"max_len"`
+	BatchSize   int    `config:"batch_size" json:"batch_size"`
+}
@@ -0,0 +1,229 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+const (
+	formatJSON    = "json"
+	formatParquet = "parquet"
+)
+
+// partition holds the messages of one topic and hour until they are uploaded.
+type partition struct {
+	path      string
+	topic     string
+	msgList   []*message.Message
+	size      int
+	createdAt time.Time
+}
+
+// Client archives messages to s3 compatible object storage, partitioned by
+// topic, date and hour.
+type Client struct {
+	config *Config
+	schema string
+	client *minio.Client
+
+	partitions map[string]*partition
+
+	mux sync.Mutex
+}
+
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config:     config,
+		partitions: make(map[string]*partition),
+	}
+	if config.Format == formatParquet {
+		schema, err := os.ReadFile(config.ParquetSchema)
+		if err != nil {
+			return nil, fmt.Errorf("read parquet schema fail: %w", err)
+		}
+		c.schema = string(schema)
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	log.Infof("connect: %s/%s", c.config.Endpoint, c.config.Bucket)
+	client, err := minio.New(c.config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(c.config.AccessKey, c.config.SecretKey, ""),
+		Secure: c.config.UseSSL,
+		Region: c.config.Region,
+	})
+	if err != nil {
+		return err
+	}
+
+	ok, err := client.BucketExists(context.Background(), c.config.Bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("bucket %s does not exist", c.config.Bucket)
+	}
+
+	c.client = client
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	return nil
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("s3 %s/%s publish", c.config.Endpoint, c.config.Bucket)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m, ok := <-msgChan:
+			if !ok {
+				c.flush(true)
+				log.Infof("s3 %s/%s close", c.config.Endpoint, c.config.Bucket)
+				return
+			}
+			c.Publish([]*message.Message{m})
+		case <-ticker.C:
+			c.flush(false)
+		}
+	}
+}
+
+// Publish adds messages to their partitions and uploads the partitions that
+// reached the size threshold. Messages that aren't json are dead-lettered.
+func (c *Client) Publish(msgList []*message.Message) {
+	c.mux.Lock()
+	for _, m := range msgList {
+		if !json.Valid(m.GetData().Body) {
+			log.Infof("Invalid json nsq message of topic %s", m.GetTopic())
+			m.DeadLetter("invalid json")
+			continue
+		}
+		t := time.Unix(0, m.GetData().Timestamp).UTC()
+		path := fmt.Sprintf("%s%s/date=%s/hour=%s/", c.config.Prefix, m.GetTopic(), t.Format("2006-01-02"), t.Format("15"))
+		p, ok := c.partitions[path]
+		if !ok {
+			p = &partition{path: path, topic: m.GetTopic(), createdAt: time.Now()}
+			c.partitions[path] = p
+		}
+		p.msgList = append(p.msgList, m)
+		p.size += len(m.GetData().Body)
+	}
+	c.mux.Unlock()
+
+	c.flush(false)
+}
+
+// flush uploads partitions over the size threshold or older than the flush
+// interval, or every partition when all is true.
+func (c *Client) flush(all bool) {
+	maxSize := c.config.MaxSize * 1024 * 1024
+	interval := time.Duration(c.config.FlushInterval) * time.Second
+
+	c.mux.Lock()
+	due := make([]*partition, 0)
+	for path, p := range c.partitions {
+		if all || (maxSize > 0 && p.size >= maxSize) || time.Since(p.createdAt) >= interval {
+			due = append(due, p)
+			delete(c.partitions, path)
+		}
+	}
+	c.mux.Unlock()
+
+	for _, p := range due {
+		err := c.upload(p)
+		for _, m := range p.msgList {
+			if err != nil {
+				m.Requeue()
+			} else {
+				m.GetData().Finish()
+			}
+		}
+		if err != nil {
+			log.Errorf("Upload %d messages to %s fail: %v", len(p.msgList), p.path, err)
+		}
+	}
+}
+
+func (c *Client) upload(p *partition) error {
+	var (
+		buf         bytes.Buffer
+		ext         string
+		contentType string
+		err         error
+	)
+	if c.config.Format == formatParquet {
+		ext, contentType = ".parquet", "application/vnd.apache.parquet"
+		err = c.encodeParquet(&buf, p.msgList)
+	} else {
+		ext, contentType = ".jsonl.gz", "application/gzip"
+		err = encodeJSON(&buf, p.msgList)
+	}
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%s-%d-%s%s", p.path, p.topic, time.Now().UnixNano(), hex.EncodeToString(suffix), ext)
+	_, err = c.client.PutObject(context.Background(), c.config.Bucket, name, &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// encodeJSON writes messages as gzipped json lines.
+func encodeJSON(buf *bytes.Buffer, msgList []*message.Message) error {
+	zw := gzip.NewWriter(buf)
+	var line bytes.Buffer
+	for _, m := range msgList {
+		line.Reset()
+		if err := json.Compact(&line, m.GetData().Body); err != nil {
+			return err
+		}
+		line.WriteByte('\n')
+		if _, err := zw.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// encodeParquet writes messages as a parquet file with the configured schema.
+func (c *Client) encodeParquet(buf *bytes.Buffer, msgList []*message.Message) error {
+	pw, err := writer.NewJSONWriterFromWriter(c.schema, buf, 4)
+	if err != nil {
+		return err
+	}
+	for _, m := range msgList {
+		if err := pw.Write(string(m.GetData().Body)); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
@@ -0,0 +1,355 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+const testBucket = "archive"
+
+// server is a minimal s3 endpoint holding a single bucket. It records the
+// objects put to it, or fails every put with status when it is set.
+type server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	status  int
+}
+
+func newServer(t *testing.T, status int) *server {
+	s := &server{
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+		status:  status,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		bucket, key := path, ""
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			bucket, key = path[:i], path[i+1:]
+		}
+		if bucket != testBucket {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodHead && key == "":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut && key != "":
+			if s.status != 0 {
+				w.Header().Set("Content-Type", "application/xml")
+				w.WriteHeader(s.status)
+				_, _ = io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+				return
+			}
+			body, err := readBody(r)
+			if err != nil {
+				t.Errorf("read object %s: %v", key, err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.mu.Lock()
+			s.objects[key] = body
+			s.types[key] = r.Header.Get("Content-Type")
+			s.mu.Unlock()
+			w.Header().Set("ETag", `"etag"`)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// readBody returns the payload of a put, decoding the aws-chunked encoding
+// minio uses to stream signed payloads over plain http.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("X-Amz-Decoded-Content-Length") == "" {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		header, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(header), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(&body, br, size); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+	}
+}
+
+// keys returns the sorted names of the stored objects.
+func (s *server) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *server) object(key string) ([]byte, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[key], s.types[key]
+}
+
+func newClient(t *testing.T, s *server, config *Config) *Client {
+	config.Endpoint = strings.TrimPrefix(s.URL, "http://")
+	config.Region = "us-east-1"
+	config.AccessKey = "access"
+	config.SecretKey = "secret"
+	if config.Bucket == "" {
+		config.Bucket = testBucket
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = 3600
+	}
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// gunzipLines returns the lines of a gzipped json lines object.
+func gunzipLines(t *testing.T, data []byte) []string {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	lines, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read gzip: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(lines), "\n"), "\n")
+}
+
+func TestConnectMissingBucket(t *testing.T) {
+	s := newServer(t, 0)
+	c, err := NewClient(&Config{
+		Endpoint:  strings.TrimPrefix(s.URL, "http://"),
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Bucket:    "missing",
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err == nil {
+		t.Error("Connect succeeded, want an error for a missing bucket")
+	}
+}
+
+func TestPublishPartitions(t *testing.T) {
+	s := newServer(t, 0)
+	c := newClient(t, s, &Config{Prefix: "logs/"})
+	d := outputstest.NewDelegate()
+	d.SetRequeuer(t)
+	first := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	second := first.Add(time.Hour)
+	msgList := append(
+		d.Messages("users", `{"a": 1}`, `not json`, `{"b": 2}`),
+		d.Messages("orders", `{"c": 3}`)...,
+	)
+	for i, ts := range []time.Time{first, first, second, first} {
+		msgList[i].GetData().Timestamp = ts.UnixNano()
+	}
+
+	c.Publish(msgList)
+
+	if keys := s.keys(); len(keys) != 0 {
+		t.Fatalf("uploaded %v before the flush interval", keys)
+	}
+
+	c.flush(true)
+
+	want := map[string][]string{
+		"logs/users/date=2021-01-02/hour=03/":  {`{"a":1}`},
+		"logs/users/date=2021-01-02/hour=04/":  {`{"b":2}`},
+		"logs/orders/date=2021-01-02/hour=03/": {`{"c":3}`},
+	}
+	keys := s.keys()
+	if len(keys) != len(want) {
+		t.Fatalf("uploaded %v, want %d objects", keys, len(want))
+	}
+	for _, key := range keys {
+		dir := key[:strings.LastIndexByte(key, '/')+1]
+		lines, ok := want[dir]
+		if !ok {
+			t.Errorf("unexpected object %s", key)
+			continue
+		}
+		if !strings.HasSuffix(key, ".jsonl.gz") {
+			t.Errorf("object %s, want a .jsonl.gz suffix", key)
+		}
+		data, contentType := s.object(key)
+		if contentType != "application/gzip" {
+			t.Errorf("object %s Content-Type = %q, want application/gzip", key, contentType)
+		}
+		got := gunzipLines(t, data)
+		if strings.Join(got, "\n") != strings.Join(lines, "\n") {
+			t.Errorf("object %s lines = %q, want %q", key, got, lines)
+		}
+	}
+	for i, m := range msgList {
+		want := outputstest.Finished
+		if i == 1 {
+			want = outputstest.DeadLettered
+		}
+		if got := d.Response(m); got != want {
+			t.Errorf("message %d response = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestPublishMaxSize(t *testing.T) {
+	s := newServer(t, 0)
+	c := newClient(t, s, &Config{MaxSize: 1})
+	d := outputstest.NewDelegate()
+	small := d.Messages("test", `{"a": 1}`)
+	large := d.Messages("test", `"`+strings.Repeat("a", 1024*1024)+`"`)
+
+	c.Publish(small)
+	if keys := s.keys(); len(keys) != 0 {
+		t.Fatalf("uploaded %v under the max size", keys)
+	}
+	c.Publish(large)
+
+	keys := s.keys()
+	if len(keys) != 1 {
+		t.Fatalf("uploaded %v, want 1 object over the max size", keys)
+	}
+	data, _ := s.object(keys[0])
+	if lines := gunzipLines(t, data); len(lines) != 2 {
+		t.Errorf("object has %d lines, want 2", len(lines))
+	}
+	for i, m := range append(small, large...) {
+		if got := d.Response(m); got != outputstest.Finished {
+			t.Errorf("message %d response = %d, want finished", i, got)
+		}
+	}
+}
+
+func TestPublishFlushInterval(t *testing.T) {
+	s := newServer(t, 0)
+	c := newClient(t, s, &Config{})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"a": 1}`)
+
+	c.Publish(msgList)
+	if keys := s.keys(); len(keys) != 0 {
+		t.Fatalf("uploaded %v before the flush interval", keys)
+	}
+	c.config.FlushInterval = 0
+	c.flush(false)
+
+	if keys := s.keys(); len(keys) != 1 {
+		t.Errorf("uploaded %v, want 1 object after the flush interval", keys)
+	}
+	if got := d.Response(msgList[0]); got != outputstest.Finished {
+		t.Errorf("response = %d, want finished", got)
+	}
+}
+
+func TestPublishParquet(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	err := os.WriteFile(schema, []byte(`{
+		"Tag": "name=parquet_go_root, repetitiontype=REQUIRED",
+		"Fields": [
+			{"Tag": "name=name, inname=Name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"}
+		]
+	}`), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s := newServer(t, 0)
+	c := newClient(t, s, &Config{Format: formatParquet, ParquetSchema: schema})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"name": "a"}`, `{"name": "b"}`)
+
+	c.Publish(msgList)
+	c.flush(true)
+
+	keys := s.keys()
+	if len(keys) != 1 {
+		t.Fatalf("uploaded %v, want 1 object", keys)
+	}
+	if !strings.HasSuffix(keys[0], ".parquet") {
+		t.Errorf("object %s, want a .parquet suffix", keys[0])
+	}
+	data, contentType := s.object(keys[0])
+	if contentType != "application/vnd.apache.parquet" {
+		t.Errorf("Content-Type = %q, want application/vnd.apache.parquet", contentType)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Errorf("object %s is not a parquet file", keys[0])
+	}
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Finished {
+			t.Errorf("message %d response = %d, want finished", i, got)
+		}
+	}
+}
+
+func TestPublishRequeue(t *testing.T) {
+	s := newServer(t, http.StatusForbidden)
+	c := newClient(t, s, &Config{})
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("test", `{"a": 1}`, `{"b": 2}`)
+
+	c.Publish(msgList)
+	c.flush(true)
+
+	for i, m := range msgList {
+		if got := d.Response(m); got != outputstest.Requeued {
+			t.Errorf("message %d response = %d, want requeued", i, got)
+		}
+	}
+}
+
+func TestNewClientMissingSchema(t *testing.T) {
+	_, err := NewClient(&Config{Format: formatParquet, ParquetSchema: filepath.Join(t.TempDir(), "missing.json")})
+	if err == nil {
+		t.Error("NewClient succeeded, want an error for a missing parquet schema")
+	}
+}
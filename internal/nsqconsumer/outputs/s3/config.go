@@ -0,0 +1,15 @@
+package s3
+
+type Config struct {
+	Endpoint      string `config:"endpoint" json:"endpoint"`
+	Region        string `config:"region" json:"region"`
+	AccessKey     string `config:"access_key" json:"access_key"`
+	SecretKey     string `config:"secret_key" json:"secret_key"`
+	UseSSL        bool   `config:"use_ssl" json:"use_ssl"`
+	Bucket        string `config:"bucket" json:"bucket"`
+	Prefix        string `config:"prefix" json:"prefix"`
+	Format        string `config:"format" json:"format"`
+	ParquetSchema string `config:"parquet_schema" json:"parquet_schema"`
+	MaxSize       int    `config:"max_size" json:"max_size"`
+	FlushInterval int    `config:"flush_interval" json:"flush_interval"`
+}
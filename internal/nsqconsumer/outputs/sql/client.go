@@ -0,0 +1,167 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	// Register the supported database drivers.
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+// Client inserts messages into relational tables.
+type Client struct {
+	config  *Config
+	dialect dialect
+	columns map[string][]string
+	keys    map[string][]int
+	db      *sql.DB
+}
+
+func NewClient(config *Config) (*Client, error) {
+	d, err := newDialect(config.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		config:  config,
+		dialect: d,
+		columns: make(map[string][]string),
+		keys:    make(map[string][]int),
+	}
+	for topic, t := range config.Topics {
+		c.columns[topic] = sortedColumns(t)
+		if config.Upsert {
+			c.keys[topic] = keyIndexes(t, c.columns[topic])
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect: %s", c.config.Driver)
+	db, err := sql.Open(c.config.Driver, c.config.DSN)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(c.config.MaxOpenConns)
+	db.SetMaxIdleConns(c.config.MaxOpenConns)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	c.db = db
+	return nil
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	return c.db.Close()
+}
+
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("sql %s publish", c.config.Driver)
+	outputs.RunBatch(msgChan, c.config.BatchSize, time.Second, c.Publish)
+	log.Infof("sql %s close", c.config.Driver)
+}
+
+// row returns the column values of an event, encoding objects and arrays as json.
+func row(columns []string, t *Table, event map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		v, _ := message.Lookup(event, t.Columns[column])
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			v = string(data)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Publish inserts msgList in a single transaction, finishing the messages
+// when it commits and requeueing them otherwise.
+func (c *Client) Publish(msgList []*message.Message) {
+	topics := make([]string, 0)
+	batches := make(map[string]*batch)
+	pending := make([]*message.Message, 0, len(msgList))
+	for _, m := range msgList {
+		t, ok := c.config.Topics[m.GetTopic()]
+		if !ok {
+			log.Infof("No sql table of topic %s", m.GetTopic())
+			m.DeadLetter(fmt.Sprintf("no sql table of topic %s", m.GetTopic()))
+			continue
+		}
+
+		event := make(map[string]interface{})
+		dec := json.NewDecoder(bytes.NewReader(m.GetData().Body))
+		dec.UseNumber()
+		if err := dec.Decode(&event); err != nil {
+			log.Infof("Unmarshal nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("unmarshal fail: %v", err))
+			continue
+		}
+		values, err := row(c.columns[m.GetTopic()], t, event)
+		if err != nil {
+			log.Infof("Encode columns of nsq message fail: %v", err)
+			m.DeadLetter(fmt.Sprintf("encode columns fail: %v", err))
+			continue
+		}
+
+		b, ok := batches[m.GetTopic()]
+		if !ok {
+			b = newBatch(c.keys[m.GetTopic()])
+			batches[m.GetTopic()] = b
+			topics = append(topics, m.GetTopic())
+		}
+		b.add(values)
+		pending = append(pending, m)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	err := c.insert(topics, batches)
+	for _, m := range pending {
+		if err != nil {
+			m.Requeue()
+		} else {
+			m.GetData().Finish()
+		}
+	}
+	if err != nil {
+		log.Errorf("Insert %d rows fail: %v", len(pending), err)
+	}
+}
+
+func (c *Client) insert(topics []string, batches map[string]*batch) error {
+	tx, err := c.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		b := batches[topic]
+		query := insertStatement(c.dialect, c.config.Topics[topic], c.columns[topic], len(b.rows), c.config.Upsert)
+		if _, err := tx.Exec(query, b.args()...); err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				log.Errorf("Rollback fail: %v", rerr)
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,16 @@
+package sql
+
+type Table struct {
+	Table   string            `config:"table" json:"table"`
+	Columns map[string]string `config:"columns" json:"columns"`
+	Keys    []string          `config:"keys" json:"keys"`
+}
+
+type Config struct {
+	Driver       string            `config:"driver" json:"driver"`
+	DSN          string            `config:"dsn" json:"dsn"`
+	Upsert       bool              `config:"upsert" json:"upsert"`
+	BatchSize    int               `config:"batch_size" json:"batch_size"`
+	MaxOpenConns int               `config:"max_open_conns" json:"max_open_conns"`
+	Topics       map[string]*Table `config:"topics" json:"topics"`
+}
@@ -0,0 +1,188 @@
+package sql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dialect builds the insert statements of a database.
+type dialect interface {
+	quote(name string) string
+	placeholder(i int) string
+	upsert(keys, columns []string) string
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlDialect) placeholder(int) string {
+	return "?"
+}
+
+func (d mysqlDialect) upsert(keys, columns []string) string {
+	sets := make([]string, 0, len(columns))
+	for _, column := range updateColumns(keys, columns) {
+		sets = append(sets, fmt.Sprintf("%s=VALUES(%s)", d.quote(column), d.quote(column)))
+	}
+	if len(sets) == 0 {
+		// Keep the existing row when every column is a key.
+		column := d.quote(keys[0])
+		return fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s=%s", column, column)
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ",")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDialect) placeholder(i int) string {
+	return fmt.Sprintf("$%d", i)
+}
+
+func (d postgresDialect) upsert(keys, columns []string) string {
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quoted = append(quoted, d.quote(key))
+	}
+	sets := make([]string, 0, len(columns))
+	for _, column := range updateColumns(keys, columns) {
+		sets = append(sets, fmt.Sprintf("%s=EXCLUDED.%s", d.quote(column), d.quote(column)))
+	}
+	if len(sets) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(quoted, ","))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(quoted, ","), strings.Join(sets, ","))
+}
+
+func newDialect(driver string) (dialect, error) {
+	switch driver {
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "postgres":
+		return postgresDialect{}, nil
+	}
+	return nil, fmt.Errorf("unsupported sql driver %q", driver)
+}
+
+// updateColumns returns the columns that are not keys.
+func updateColumns(keys, columns []string) []string {
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	update := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !isKey[column] {
+			update = append(update, column)
+		}
+	}
+	return update
+}
+
+// sortedColumns returns the columns of a table in a stable order.
+func sortedColumns(t *Table) []string {
+	columns := make([]string, 0, len(t.Columns))
+	for column := range t.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// keyIndexes returns the positions of the keys of t in columns.
+func keyIndexes(t *Table, columns []string) []int {
+	indexes := make([]int, 0, len(t.Keys))
+	for _, key := range t.Keys {
+		for i, column := range columns {
+			if column == key {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// batch holds the rows inserted into a table. When upserting, a row replaces
+// the earlier row with the same keys, as a statement can't update a row twice.
+type batch struct {
+	keys  []int
+	rows  [][]interface{}
+	index map[string]int
+}
+
+func newBatch(keys []int) *batch {
+	return &batch{
+		keys:  keys,
+		index: make(map[string]int),
+	}
+}
+
+func (b *batch) add(values []interface{}) {
+	if len(b.keys) == 0 {
+		b.rows = append(b.rows, values)
+		return
+	}
+
+	var sb strings.Builder
+	for _, i := range b.keys {
+		fmt.Fprintf(&sb, "%v\x00", values[i])
+	}
+	key := sb.String()
+	if i, ok := b.index[key]; ok {
+		b.rows[i] = values
+		return
+	}
+	b.index[key] = len(b.rows)
+	b.rows = append(b.rows, values)
+}
+
+// args returns the values of all the rows.
+func (b *batch) args() []interface{} {
+	args := make([]interface{}, 0, len(b.rows)*len(b.rows[0]))
+	for _, row := range b.rows {
+		args = append(args, row...)
+	}
+	return args
+}
+
+// insertStatement returns a multi-row insert of rows rows into t.
+func insertStatement(d dialect, t *Table, columns []string, rows int, upsert bool) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, d.quote(column))
+	}
+	parts := strings.Split(t.Table, ".")
+	for i, part := range parts {
+		parts[i] = d.quote(part)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", strings.Join(parts, "."), strings.Join(quoted, ","))
+	n := 1
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('(')
+		for j := range columns {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(d.placeholder(n))
+			n++
+		}
+		sb.WriteByte(')')
+	}
+	if upsert {
+		sb.WriteString(d.upsert(t.Keys, columns))
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInsertStatement(t *testing.T) {
+	table := &Table{
+		Table:   "logs.events",
+		Columns: map[string]string{"id": "id", "msg": "message", "ts": "time"},
+		Keys:    []string{"id"},
+	}
+	columns := sortedColumns(table)
+	tests := []struct {
+		name    string
+		dialect dialect
+		upsert  bool
+		want    string
+	}{
+		{
+			name:    "mysql",
+			dialect: mysqlDialect{},
+			want:    "INSERT INTO `logs`.`events` (`id`,`msg`,`ts`) VALUES (?,?,?),(?,?,?)",
+		},
+		{
+			name:    "mysql upsert",
+			dialect: mysqlDialect{},
+			upsert:  true,
+			want:    "INSERT INTO `logs`.`events` (`id`,`msg`,`ts`) VALUES (?,?,?),(?,?,?) ON DUPLICATE KEY UPDATE `msg`=VALUES(`msg`),`ts`=VALUES(`ts`)",
+		},
+		{
+			name:    "postgres upsert",
+			dialect: postgresDialect{},
+			upsert:  true,
+			want:    `INSERT INTO "logs"."events" ("id","msg","ts") VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "msg"=EXCLUDED."msg","ts"=EXCLUDED."ts"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertStatement(tt.dialect, table, columns, 2, tt.upsert); got != tt.want {
+				t.Errorf("insertStatement() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchKeepsLastRowOfKey(t *testing.T) {
+	table := &Table{
+		Columns: map[string]string{"id": "id", "msg": "message", "region": "region"},
+		Keys:    []string{"region", "id"},
+	}
+	b := newBatch(keyIndexes(table, sortedColumns(table)))
+	b.add([]interface{}{1, "a", "eu"})
+	b.add([]interface{}{2, "b", "eu"})
+	b.add([]interface{}{1, "c", "us"})
+	b.add([]interface{}{1, "d", "eu"})
+
+	want := []interface{}{1, "d", "eu", 2, "b", "eu", 1, "c", "us"}
+	if got := b.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %v, want %v", got, want)
+	}
+}
+
+func TestBatchWithoutKeys(t *testing.T) {
+	b := newBatch(nil)
+	b.add([]interface{}{1, "a"})
+	b.add([]interface{}{1, "a"})
+
+	if len(b.rows) != 2 {
+		t.Errorf("got %d rows, want 2", len(b.rows))
+	}
+}
@@ -0,0 +1,70 @@
+package outputs
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Event is the data output templates are executed with.
+type Event struct {
+	Topic     string
+	Timestamp time.Time
+	Attempts  uint16
+	Fields    map[string]interface{}
+}
+
+// NewEvent creates the template data of m with the given decoded fields.
+func NewEvent(m *message.Message, fields map[string]interface{}) *Event {
+	return &Event{
+		Topic:     m.GetTopic(),
+		Timestamp: time.Unix(0, m.GetData().Timestamp),
+		Attempts:  m.GetData().Attempts,
+		Fields:    fields,
+	}
+}
+
+// Template renders names such as topics or keys from messages, for example
+// "{{.Topic}}_{{.Fields.service}}".
+type Template struct {
+	tmpl       *template.Template
+	needFields bool
+}
+
+// NewTemplate parses text as a text/template executed with an Event. Rendering
+// fails when the template refers to a field missing from the event.
+func NewTemplate(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{
+		tmpl:       tmpl,
+		needFields: strings.Contains(text, ".Fields"),
+	}, nil
+}
+
+// Render executes the template for m, decoding the message body only when the
+// template refers to its fields.
+func (t *Template) Render(m *message.Message) (string, error) {
+	var fields map[string]interface{}
+	if t.needFields {
+		if err := json.Unmarshal(m.GetData().Body, &fields); err != nil {
+			return "", err
+		}
+	}
+
+	return t.Execute(NewEvent(m, fields))
+}
+
+// Execute renders the template for an already decoded event.
+func (t *Template) Execute(event *Event) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, event); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
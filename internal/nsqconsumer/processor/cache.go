@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache caches lookups, evicting the least recently used beyond its size.
+type lruCache struct {
+	size int
+
+	mux   sync.Mutex
+	list  *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		list:  list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.list.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (c *lruCache) Add(key string, value interface{}) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		c.list.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.list.PushFront(&lruEntry{key: key, value: value})
+	if c.list.Len() > c.size {
+		oldest := c.list.Back()
+		c.list.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Purge() {
+	c.mux.Lock()
+	c.list.Init()
+	c.items = make(map[string]*list.Element)
+	c.mux.Unlock()
+}
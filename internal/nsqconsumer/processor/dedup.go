@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Dedup keys.
+const (
+	DedupMessageID = "message-id"
+	DedupField     = "field"
+	DedupHash      = "hash"
+)
+
+// DedupStore records the keys seen within a window.
+type DedupStore interface {
+	// Add records key for window, returning false when it is recorded
+	// already.
+	Add(key string, window time.Duration) (bool, error)
+	// Set records key for window, whether it is recorded already or not.
+	Set(key string, window time.Duration) error
+	Remove(key string) error
+	Close() error
+}
+
+// Deduper drops the events whose key was seen within the window. A key is
+// only pending until its message is finished, then it is kept for the
+// window. Pending keys expire after the pending timeout and are removed when
+// their message is requeued, so that the redeliveries of messages lost in
+// flight or failed by the output pass.
+type Deduper struct {
+	store   DedupStore
+	key     string
+	field   string
+	window  time.Duration
+	pending time.Duration
+}
+
+// NewDeduper returns a Deduper keeping the keys of finished messages for
+// window. pending should not exceed the msg-timeout of nsqd, after which a
+// message lost in flight is redelivered, and is capped at window.
+func NewDeduper(store DedupStore, key, field string, window, pending time.Duration) *Deduper {
+	if pending <= 0 || pending > window {
+		pending = window
+	}
+	return &Deduper{
+		store:   store,
+		key:     key,
+		field:   field,
+		window:  window,
+		pending: pending,
+	}
+}
+
+// eventKey returns the dedup key of e, prefixed by the topic.
+func (d *Deduper) eventKey(e *Event) (string, error) {
+	switch d.key {
+	case DedupField:
+		fields, err := e.Fields()
+		if err != nil {
+			return "", err
+		}
+		v, ok := message.Lookup(fields, d.field)
+		if !ok || v == nil {
+			return "", fmt.Errorf("missing dedup field %s", d.field)
+		}
+		return e.Topic + ":" + fmt.Sprint(v), nil
+	case DedupHash:
+		sum := sha1.Sum(e.Message.Body)
+		return e.Topic + ":" + hex.EncodeToString(sum[:]), nil
+	default:
+		return e.Topic + ":" + string(e.Message.ID[:]), nil
+	}
+}
+
+func (d *Deduper) Process(e *Event) ([]*Event, error) {
+	key, err := d.eventKey(e)
+	if err != nil {
+		log.Infof("Get dedup key of %s message fail: %v", e.Topic, err)
+		return []*Event{e}, nil
+	}
+
+	added, err := d.store.Add(key, d.pending)
+	if err != nil {
+		log.Errorf("Add dedup key fail: %v", err)
+		return []*Event{e}, nil
+	}
+	if !added {
+		return nil, nil
+	}
+	e.Message.Delegate = &dedupDelegate{MessageDelegate: e.Message.Delegate, deduper: d, key: key}
+	return []*Event{e}, nil
+}
+
+// dedupDelegate keeps the key of a finished message for the window and
+// removes the key of a requeued one.
+type dedupDelegate struct {
+	nsq.MessageDelegate
+	deduper *Deduper
+	key     string
+}
+
+func (d *dedupDelegate) OnFinish(m *nsq.Message) {
+	if err := d.deduper.store.Set(d.key, d.deduper.window); err != nil {
+		log.Errorf("Set dedup key fail: %v", err)
+	}
+	d.MessageDelegate.OnFinish(m)
+}
+
+func (d *dedupDelegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	if err := d.deduper.store.Remove(d.key); err != nil {
+		log.Errorf("Remove dedup key fail: %v", err)
+	}
+	d.MessageDelegate.OnRequeue(m, delay, backoff)
+}
+
+// MemoryStore keeps the keys of a window in memory, evicting the oldest ones
+// beyond maxKeys.
+type MemoryStore struct {
+	maxKeys int
+
+	mux    sync.Mutex
+	keys   map[string]time.Time
+	expiry []memoryKey
+}
+
+type memoryKey struct {
+	key     string
+	expires time.Time
+}
+
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	return &MemoryStore{
+		maxKeys: maxKeys,
+		keys:    make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) Add(key string, window time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Forget the expired keys and the oldest beyond maxKeys. Topics with a
+	// longer window may hold back the expired keys of others until their
+	// own expire, they are still not found by the check below.
+	for len(s.expiry) > 0 && (len(s.keys) >= s.maxKeys || !s.expiry[0].expires.After(now)) {
+		k := s.expiry[0]
+		s.expiry[0] = memoryKey{}
+		s.expiry = s.expiry[1:]
+		if expires, ok := s.keys[k.key]; ok && expires.Equal(k.expires) {
+			delete(s.keys, k.key)
+		}
+	}
+
+	if expires, ok := s.keys[key]; ok && expires.After(now) {
+		return false, nil
+	}
+	expires := now.Add(window)
+	s.keys[key] = expires
+	s.expiry = append(s.expiry, memoryKey{key: key, expires: expires})
+	return true, nil
+}
+
+func (s *MemoryStore) Set(key string, window time.Duration) error {
+	expires := time.Now().Add(window)
+
+	s.mux.Lock()
+	s.keys[key] = expires
+	s.expiry = append(s.expiry, memoryKey{key: key, expires: expires})
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Remove(key string) error {
+	s.mux.Lock()
+	delete(s.keys, key)
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// RedisStore shares the keys of a window between instances with SET NX.
+type RedisStore struct {
+	prefix string
+	client *redis.Client
+}
+
+func NewRedisStore(addr, password string, db int, prefix string) (*RedisStore, error) {
+	log.Infof("connect dedup: %s", addr)
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisStore{
+		prefix: prefix,
+		client: client,
+	}, nil
+}
+
+func (s *RedisStore) Add(key string, window time.Duration) (bool, error) {
+	return s.client.SetNX(context.Background(), s.prefix+key, 1, window).Result()
+}
+
+func (s *RedisStore) Set(key string, window time.Duration) error {
+	return s.client.Set(context.Background(), s.prefix+key, 1, window).Err()
+}
+
+func (s *RedisStore) Remove(key string) error {
+	return s.client.Del(context.Background(), s.prefix+key).Err()
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
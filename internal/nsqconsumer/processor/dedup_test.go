@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// newMessages returns a message of topic for each body, responding to d.
+func newMessages(d *outputstest.Delegate, topic string, bodies ...string) []*nsq.Message {
+	msgList := make([]*nsq.Message, 0, len(bodies))
+	for _, m := range d.Messages(topic, bodies...) {
+		msgList = append(msgList, m.GetData())
+	}
+	return msgList
+}
+
+// passes reports whether p keeps the event of m.
+func passes(t *testing.T, p Processor, topic string, m *nsq.Message) bool {
+	t.Helper()
+	events, err := p.Process(NewEvent(topic, m))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	return len(events) > 0
+}
+
+func TestDeduper(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(m *nsq.Message)
+		wait    time.Duration
+		want    bool
+	}{
+		{name: "pending", respond: func(m *nsq.Message) {}, want: false},
+		{name: "pending expired", respond: func(m *nsq.Message) {}, wait: 50 * time.Millisecond, want: true},
+		{name: "finished", respond: func(m *nsq.Message) { m.Finish() }, wait: 50 * time.Millisecond, want: false},
+		{name: "requeued", respond: func(m *nsq.Message) { m.Requeue(-1) }, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dedup := NewDeduper(NewMemoryStore(100), DedupHash, "", time.Hour, 20*time.Millisecond)
+			d := outputstest.NewDelegate()
+			msgList := newMessages(d, "logs", `{"n":1}`, `{"n":1}`)
+
+			if !passes(t, dedup, "logs", msgList[0]) {
+				t.Fatal("first message dropped")
+			}
+			tt.respond(msgList[0])
+			time.Sleep(tt.wait)
+			if got := passes(t, dedup, "logs", msgList[1]); got != tt.want {
+				t.Errorf("duplicate passes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeduperKeys(t *testing.T) {
+	d := outputstest.NewDelegate()
+	msgList := newMessages(d, "logs", `{"id":1,"n":1}`, `{"id":1,"n":2}`, `{"id":2,"n":1}`, `{"n":3}`, `{"n":3}`)
+
+	dedup := NewDeduper(NewMemoryStore(100), DedupField, "id", time.Hour, time.Hour)
+	want := []bool{true, false, true, true, true}
+	for i, m := range msgList {
+		if got := passes(t, dedup, "logs", m); got != want[i] {
+			t.Errorf("message %d passes = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// Keys are prefixed by the topic.
+	dedup = NewDeduper(NewMemoryStore(100), DedupMessageID, "", time.Hour, time.Hour)
+	if !passes(t, dedup, "logs", msgList[0]) || !passes(t, dedup, "other", msgList[0]) {
+		t.Error("message of another topic dropped")
+	}
+	if passes(t, dedup, "logs", msgList[0]) {
+		t.Error("redelivered message passes")
+	}
+}
+
+func TestMemoryStoreMaxKeys(t *testing.T) {
+	s := NewMemoryStore(2)
+	for _, key := range []string{"a", "b", "c"} {
+		if added, _ := s.Add(key, time.Hour); !added {
+			t.Fatalf("Add(%s) = false, want true", key)
+		}
+	}
+	if added, _ := s.Add("c", time.Hour); added {
+		t.Error("Add(c) = true, want false")
+	}
+	if added, _ := s.Add("a", time.Hour); !added {
+		t.Error("Add(a) = false after evicting it, want true")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStore(mr.Addr(), "", 0, "dedup:")
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	defer s.Close()
+
+	if added, err := s.Add("logs:a", time.Minute); err != nil || !added {
+		t.Fatalf("Add = %v, %v, want true", added, err)
+	}
+	if added, _ := s.Add("logs:a", time.Minute); added {
+		t.Error("Add of a pending key = true, want false")
+	}
+	if err := s.Set("logs:a", time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := mr.TTL("dedup:logs:a"); ttl != time.Hour {
+		t.Errorf("TTL = %v after Set, want 1h", ttl)
+	}
+	if err := s.Remove("logs:a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if added, _ := s.Add("logs:a", time.Minute); !added {
+		t.Error("Add of a removed key = false, want true")
+	}
+	mr.FastForward(2 * time.Minute)
+	if added, _ := s.Add("logs:a", time.Minute); !added {
+		t.Error("Add of an expired key = false, want true")
+	}
+}
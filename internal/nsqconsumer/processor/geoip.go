@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"net"
+	"sync"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// geoIPRecord holds the fields read from MaxMind City, Country and ASN
+// databases.
+type geoIPRecord struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	ASNumber       uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// fields returns the fields of r named after the Elastic Common Schema,
+// relative to the enriched object.
+func (r *geoIPRecord) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	add := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	add("geo.continent_code", r.Continent.Code)
+	add("geo.country_iso_code", r.Country.ISOCode)
+	add("geo.country_name", r.Country.Names["en"])
+	if len(r.Subdivisions) > 0 {
+		add("geo.region_iso_code", r.Subdivisions[0].ISOCode)
+		add("geo.region_name", r.Subdivisions[0].Names["en"])
+	}
+	add("geo.city_name", r.City.Names["en"])
+	add("geo.timezone", r.Location.TimeZone)
+	if r.Location.Latitude != nil && r.Location.Longitude != nil {
+		fields["geo.location.lat"] = *r.Location.Latitude
+		fields["geo.location.lon"] = *r.Location.Longitude
+	}
+	if r.ASNumber != 0 {
+		fields["as.number"] = r.ASNumber
+	}
+	add("as.organization.name", r.ASOrganization)
+	return fields
+}
+
+// GeoIPDatabase looks up IPs in a MaxMind database file, which is read in
+// memory so that it can be replaced while in use.
+type GeoIPDatabase struct {
+	path  string
+	cache *lruCache
+
+	mux     sync.RWMutex
+	reader  *maxminddb.Reader
+	version fileVersion
+}
+
+// OpenGeoIPDatabase opens the database at path, caching the lookups of up to
+// cacheSize IPs.
+func OpenGeoIPDatabase(path string, cacheSize int) (*GeoIPDatabase, error) {
+	db := &GeoIPDatabase{
+		path:  path,
+		cache: newLRUCache(cacheSize),
+	}
+	if _, err := db.Reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *GeoIPDatabase) Path() string {
+	return db.path
+}
+
+func (db *GeoIPDatabase) Reload() (bool, error) {
+	db.mux.RLock()
+	version := db.version
+	db.mux.RUnlock()
+
+	data, version, err := readChanged(db.path, version)
+	if err != nil || data == nil {
+		return false, err
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return false, err
+	}
+
+	db.mux.Lock()
+	db.reader = reader
+	db.version = version
+	db.mux.Unlock()
+	db.cache.Purge()
+	return true, nil
+}
+
+// Lookup returns the fields of ip, empty when it is not in the database.
+func (db *GeoIPDatabase) Lookup(ip net.IP) (map[string]interface{}, error) {
+	key := ip.String()
+	if fields, ok := db.cache.Get(key); ok {
+		return fields.(map[string]interface{}), nil
+	}
+
+	db.mux.RLock()
+	reader := db.reader
+	db.mux.RUnlock()
+	var record geoIPRecord
+	if err := reader.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+	fields := record.fields()
+	db.cache.Add(key, fields)
+	return fields, nil
+}
+
+// GeoIP enriches events with the location and autonomous system of the IP in
+// a field, looked up in its databases. Events without a valid IP, or with one
+// not found, pass as they are.
+type GeoIP struct {
+	databases []*GeoIPDatabase
+	field     string
+	target    string
+}
+
+// NewGeoIP returns a GeoIP enriching the object at target with the lookups
+// of the IP in field.
+func NewGeoIP(databases []*GeoIPDatabase, field, target string) *GeoIP {
+	return &GeoIP{
+		databases: databases,
+		field:     field,
+		target:    target,
+	}
+}
+
+func (g *GeoIP) Process(e *Event) ([]*Event, error) {
+	fields, err := e.Fields()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := message.Lookup(fields, g.field)
+	s, _ := value.(string)
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return []*Event{e}, nil
+	}
+
+	var enriched bool
+	for _, db := range g.databases {
+		found, err := db.Lookup(ip)
+		if err != nil {
+			log.Errorf("Look up %s in %s fail: %v", ip, db.path, err)
+			continue
+		}
+		for key, value := range found {
+			message.Set(fields, g.target+"."+key, value)
+			enriched = true
+		}
+	}
+	if !enriched {
+		return []*Event{e}, nil
+	}
+	return []*Event{e}, e.SetFields(fields)
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// grokReference matches %{NAME}, %{NAME:field} and %{NAME:field:type} in grok
+// expressions.
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(int|float))?\}`)
+
+// grokCapture is the field a group of a Grok is stored in.
+type grokCapture struct {
+	field string
+	typ   string
+}
+
+// Grok is a compiled grok expression: a regular expression in which
+// %{NAME:field:type} is replaced by the named pattern, captured in the dotted
+// field converted to type, int or float. Named groups of the regular
+// expression are captured in the field of their name.
+type Grok struct {
+	re       *regexp.Regexp
+	captures []grokCapture
+}
+
+// GrokPatterns returns the pattern library with the custom patterns added,
+// replacing the library ones of the same name.
+func GrokPatterns(custom map[string]string) map[string]string {
+	patterns := make(map[string]string, len(grokPatterns)+len(custom))
+	for name, pattern := range grokPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range custom {
+		patterns[name] = pattern
+	}
+	return patterns
+}
+
+// ReadGrokPatterns reads the custom patterns of the files of dir, each line
+// a name followed by whitespace and its pattern, lines starting with # being comments.
+func ReadGrokPatterns(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			// The name ends at the first run of spaces or tabs.
+			i := strings.IndexFunc(text, unicode.IsSpace)
+			if i < 0 {
+				return nil, fmt.Errorf("invalid grok pattern at %s:%d", file, line)
+			}
+			patterns[text[:i]] = strings.TrimSpace(text[i:])
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
+
+// CompileGrok compiles expr with the named patterns.
+func CompileGrok(expr string, patterns map[string]string) (*Grok, error) {
+	var names []grokCapture
+	expanded, err := expandGrok(expr, patterns, &names, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("compile grok %q fail: %w", expr, err)
+	}
+
+	// Groups named by expandGrok are grok<index of names>, the others are
+	// named groups of the expression itself.
+	captures := make([]grokCapture, len(re.SubexpNames()))
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(name, "grok__") {
+			n, _ := strconv.Atoi(strings.TrimPrefix(name, "grok__"))
+			captures[i] = names[n]
+			continue
+		}
+		captures[i] = grokCapture{field: name}
+	}
+	return &Grok{re: re, captures: captures}, nil
+}
+
+// expandGrok replaces the pattern references of expr, appending the captured
+// fields to names. expanding holds the patterns being expanded, to reject
+// recursive ones.
+func expandGrok(expr string, patterns map[string]string, names *[]grokCapture, expanding map[string]bool) (string, error) {
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(expr, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		sub := grokReference.FindStringSubmatch(ref)
+		name, field, typ := sub[1], sub[2], sub[3]
+		pattern, ok := patterns[name]
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern %s", name)
+			return ""
+		}
+		if expanding[name] {
+			err = fmt.Errorf("recursive grok pattern %s", name)
+			return ""
+		}
+
+		expanding[name] = true
+		pattern, err = expandGrok(pattern, patterns, names, expanding)
+		delete(expanding, name)
+		if err != nil {
+			return ""
+		}
+		if field == "" {
+			return "(?:" + pattern + ")"
+		}
+		*names = append(*names, grokCapture{field: field, typ: typ})
+		return fmt.Sprintf("(?P<grok__%d>%s)", len(*names)-1, pattern)
+	})
+	return expanded, err
+}
+
+// Match sets the captured fields of line in fields, reporting whether line
+// matches.
+func (g *Grok) Match(line string, fields map[string]interface{}) bool {
+	loc := g.re.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return false
+	}
+
+	for i, capture := range g.captures {
+		if capture.field == "" || loc[2*i] < 0 {
+			continue
+		}
+		value := line[loc[2*i]:loc[2*i+1]]
+		message.Set(fields, capture.field, convertCapture(value, capture.typ))
+	}
+	return true
+}
+
+// convertCapture converts a captured value to typ, keeping the string when it
+// doesn't convert.
+func convertCapture(value, typ string) interface{} {
+	switch typ {
+	case "int":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
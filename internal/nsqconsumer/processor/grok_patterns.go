@@ -0,0 +1,67 @@
+package processor
+
+// grokPatterns is the library of named patterns available to grok
+// expressions, adapted from the logstash patterns to RE2.
+var grokPatterns = map[string]string{
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"INT":          `[+-]?[0-9]+`,
+	"BASE10NUM":    `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":       `%{BASE10NUM}`,
+	"BASE16NUM":    `[+-]?(?:0x)?[0-9A-Fa-f]+`,
+	"POSINT":       `[1-9][0-9]*`,
+	"NONNEGINT":    `[0-9]+`,
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"SPACE":        `\s*`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":           `%{QUOTEDSTRING}`,
+	"UUID":         `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+
+	"IPV4":     `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":     `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:[0-9A-Fa-f]{1,4}|%{IPV4})?`,
+	"IP":       `%{IPV6}|%{IPV4}`,
+	"HOSTNAME": `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*\.?\b`,
+	"IPORHOST": `%{IP}|%{HOSTNAME}`,
+	"HOSTPORT": `%{IPORHOST}:%{POSINT}`,
+
+	"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+	"URIPROTO":     `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIHOST":      `%{IPORHOST}(?::%{POSINT})?`,
+	"URI":          `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+
+	"MONTH":             `\b(?:[Jj]an(?:uary|uar)?|[Ff]eb(?:ruary|ruar)?|[Mm](?:a|ä)?r(?:ch|z)?|[Aa]pr(?:il)?|[Mm]a(?:y|i)?|[Jj]un(?:e|i)?|[Jj]ul(?:y|i)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo](?:c|k)?t(?:ober)?|[Nn]ov(?:ember)?|[Dd]e(?:c|z)(?:ember)?)\b`,
+	"MONTHNUM":          `0?[1-9]|1[0-2]`,
+	"MONTHDAY":          `(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9]`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `[0-9]{4}`,
+	"HOUR":              `2[0123]|[01]?[0-9]`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}(?::?%{MINUTE})`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} [+-]?[0-9]+`,
+	"LOGLEVEL":          `[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo(?:rmation)?|INFO(?:RMATION)?|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?`,
+
+	"SYSLOGTIMESTAMP": `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"PROG":            `[\x21-\x5a\x5c\x5e-\x7e]+`,
+	"SYSLOGPROG":      `%{PROG:process.name}(?:\[%{POSINT:process.pid:int}\])?`,
+	"SYSLOGHOST":      `%{IPORHOST}`,
+	"SYSLOGPRI":       `<%{NONNEGINT:log.syslog.priority:int}>`,
+	// SYSLOGLINE parses RFC 3164 lines, with an optional priority.
+	"SYSLOGLINE": `(?:%{SYSLOGPRI})?%{SYSLOGTIMESTAMP:timestamp} %{SYSLOGHOST:host.hostname} %{SYSLOGPROG}: %{GREEDYDATA:message}`,
+	// SYSLOG5424LINE parses RFC 5424 lines.
+	"SYSLOG5424LINE": `%{SYSLOGPRI}%{NONNEGINT:log.syslog.version:int} +(?:-|%{TIMESTAMP_ISO8601:timestamp}) +(?:-|%{IPORHOST:host.hostname}) +(?:-|%{NOTSPACE:process.name}) +(?:-|%{NOTSPACE:process.pid}) +(?:-|%{NOTSPACE:event.code}) +(?:-|(?:\[.*?\])+) ?%{GREEDYDATA:message}`,
+
+	// COMMONAPACHELOG and NGINX_ACCESS parse the common and combined log
+	// formats, the latter being the default access log format of nginx.
+	"COMMONAPACHELOG":   `%{IPORHOST:source.address} %{NOTSPACE:ident} %{NOTSPACE:user.name} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:http.request.method} %{NOTSPACE:url.original}(?: HTTP/%{NUMBER:http.version})?|%{DATA:http.request.raw})" %{NONNEGINT:http.response.status_code:int} (?:%{NONNEGINT:http.response.body.bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} "(?:-|%{DATA:http.request.referrer})" "(?:-|%{DATA:user_agent.original})"`,
+	"NGINX_ACCESS":      `%{COMBINEDAPACHELOG}`,
+	"NGINX_ERROR":       `(?P<timestamp>%{YEAR}/%{MONTHNUM}/%{MONTHDAY} %{TIME}) \[%{LOGLEVEL:log.level}\] %{POSINT:process.pid:int}#%{NONNEGINT:process.thread.id:int}: (?:\*%{NONNEGINT:nginx.connection_id:int} )?%{GREEDYDATA:message}`,
+}
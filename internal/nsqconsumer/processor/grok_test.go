@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadGrokPatterns(t *testing.T) {
+	dir := t.TempDir()
+	data := "# custom patterns\n" +
+		"SPACED %{WORD:a} %{INT:b}\n" +
+		"TABBED\t%{IP:client}\n" +
+		"MIXED \t  %{NUMBER:n}  \n" +
+		"\n"
+	if err := os.WriteFile(filepath.Join(dir, "custom"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err := ReadGrokPatterns(dir)
+	if err != nil {
+		t.Fatalf("ReadGrokPatterns: %v", err)
+	}
+	want := map[string]string{
+		"SPACED": "%{WORD:a} %{INT:b}",
+		"TABBED": "%{IP:client}",
+		"MIXED":  "%{NUMBER:n}",
+	}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Errorf("patterns = %v, want %v", patterns, want)
+	}
+}
+
+func TestReadGrokPatternsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "custom"), []byte("NOPATTERN\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadGrokPatterns(dir); err == nil {
+		t.Error("ReadGrokPatterns succeeded on a line without a pattern")
+	}
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Actions on the events matching no pattern.
+const (
+	ParseDrop       = "drop"
+	ParseDeadLetter = "dead-letter"
+	ParseKeep       = "keep"
+)
+
+// parseErrorField marks the events kept without matching a pattern.
+const parseErrorField = "_parse_error"
+
+var errNoMatch = errors.New("no pattern matched")
+
+// Parser turns text into fields with the first matching of its grok
+// expressions. Without a field, it parses text bodies into events holding
+// the text in the message field, and passes json objects. With a field, it
+// parses the string in that field of the events.
+type Parser struct {
+	groks        []*Grok
+	field        string
+	messageField string
+	noMatch      string
+	deadLetter   DeadLetterFunc
+}
+
+// NewParser compiles exprs with patterns, returning a Parser handling the
+// events matching none of them with noMatch. deadLetter is used by the
+// dead-letter action.
+func NewParser(exprs []string, patterns map[string]string, field, messageField, noMatch string, deadLetter DeadLetterFunc) (*Parser, error) {
+	groks := make([]*Grok, 0, len(exprs))
+	for _, expr := range exprs {
+		g, err := CompileGrok(expr, patterns)
+		if err != nil {
+			return nil, err
+		}
+		groks = append(groks, g)
+	}
+
+	return &Parser{
+		groks:        groks,
+		field:        field,
+		messageField: messageField,
+		noMatch:      noMatch,
+		deadLetter:   deadLetter,
+	}, nil
+}
+
+// ParsesText reports whether p turns text bodies into events.
+func (p *Parser) ParsesText() bool {
+	return p.field == ""
+}
+
+func (p *Parser) Process(e *Event) ([]*Event, error) {
+	var (
+		fields map[string]interface{}
+		line   string
+	)
+	if p.field == "" {
+		if message.IsObject(e.Message.Body) {
+			return []*Event{e}, nil
+		}
+		line = strings.TrimRight(string(e.Message.Body), "\r\n")
+		fields = map[string]interface{}{p.messageField: line}
+	} else {
+		var err error
+		if fields, err = e.Fields(); err != nil {
+			return nil, err
+		}
+		value, _ := message.Lookup(fields, p.field)
+		line, _ = value.(string)
+	}
+
+	for _, g := range p.groks {
+		if g.Match(line, fields) {
+			return []*Event{e}, e.SetFields(fields)
+		}
+	}
+
+	switch p.noMatch {
+	case ParseKeep:
+		fields[parseErrorField] = errNoMatch.Error()
+		return []*Event{e}, e.SetFields(fields)
+	case ParseDeadLetter:
+		p.deadLetter(message.NewMessage(e.Message, e.Topic), errNoMatch.Error())
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func TestParserNoMatch(t *testing.T) {
+	tests := []struct {
+		noMatch      string
+		wantBody     string
+		deadLettered bool
+	}{
+		{noMatch: ParseDrop},
+		{noMatch: ParseDeadLetter, deadLettered: true},
+		{noMatch: ParseKeep, wantBody: `{"_parse_error":"no pattern matched","message":"hello"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.noMatch, func(t *testing.T) {
+			var deadLettered bool
+			p, err := NewParser([]string{`%{INT:n}`}, GrokPatterns(nil), "", "message", tt.noMatch, func(m *message.Message, reason string) {
+				deadLettered = true
+			})
+			if err != nil {
+				t.Fatalf("NewParser: %v", err)
+			}
+			d := outputstest.NewDelegate()
+			m := newMessages(d, "logs", "hello\n")[0]
+
+			events, err := p.Process(NewEvent("logs", m))
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if deadLettered != tt.deadLettered {
+				t.Errorf("dead-lettered = %v, want %v", deadLettered, tt.deadLettered)
+			}
+			var body string
+			if len(events) > 0 {
+				body = string(events[0].Message.Body)
+			}
+			if body != tt.wantBody {
+				t.Errorf("event = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestParserMatch(t *testing.T) {
+	p, err := NewParser([]string{`%{WORD:user} took %{INT:ms:int}ms`}, GrokPatterns(nil), "", "message", ParseDrop, nil)
+	if err != nil {
+		t.Fatalf("NewParser: %v", err)
+	}
+	d := outputstest.NewDelegate()
+	msgList := newMessages(d, "logs", "bob took 12ms\n", `{"user":"json"}`)
+
+	tests := []string{
+		`{"message":"bob took 12ms","ms":12,"user":"bob"}`,
+		`{"user":"json"}`,
+	}
+	for i, want := range tests {
+		events, err := p.Process(NewEvent("logs", msgList[i]))
+		if err != nil || len(events) != 1 {
+			t.Fatalf("Process = %d events, %v, want 1", len(events), err)
+		}
+		if got := string(events[0].Message.Body); got != want {
+			t.Errorf("event = %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package processor handles consumed events before they are queued for the
+// output.
+package processor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// Event is a consumed message passing through the processors of its topic.
+type Event struct {
+	Topic   string
+	Message *nsq.Message
+
+	fields map[string]interface{}
+	// part is set on the events split from another, which are finished on
+	// their own when dropped.
+	part bool
+}
+
+func NewEvent(topic string, m *nsq.Message) *Event {
+	return &Event{
+		Topic:   topic,
+		Message: m,
+	}
+}
+
+// Fields returns the decoded body, decoding it on first use.
+func (e *Event) Fields() (map[string]interface{}, error) {
+	if e.fields == nil {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(e.Message.Body, &fields); err != nil {
+			return nil, err
+		}
+		e.fields = fields
+	}
+	return e.fields, nil
+}
+
+// SetFields replaces the body of e with the encoded fields.
+func (e *Event) SetFields(fields map[string]interface{}) error {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	e.Message.Body = body
+	e.fields = fields
+	return nil
+}
+
+// Split returns an event of the topic of e for each of fieldsList, each with
+// a message of its own sharing the response of the message of e: it is
+// finished once all the parts are, and requeued as soon as one of them is,
+// redelivering all of them. Parts get an id derived from the id of e, so
+// redeliveries keep the same ids.
+func (e *Event) Split(fieldsList []map[string]interface{}) ([]*Event, error) {
+	d := &splitDelegate{
+		parent:  e.Message,
+		pending: int32(len(fieldsList)),
+	}
+	events := make([]*Event, 0, len(fieldsList))
+	for i, fields := range fieldsList {
+		body, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		m := nsq.NewMessage(partID(e.Message.ID, i), body)
+		m.Timestamp = e.Message.Timestamp
+		m.Attempts = e.Message.Attempts
+		m.NSQDAddress = e.Message.NSQDAddress
+		m.Delegate = d
+		events = append(events, &Event{
+			Topic:   e.Topic,
+			Message: m,
+			fields:  fields,
+			part:    true,
+		})
+	}
+	return events, nil
+}
+
+// partID returns the id of the i-th part of the message id.
+func partID(id nsq.MessageID, i int) nsq.MessageID {
+	h := fnv.New64a()
+	h.Write(id[:])
+	h.Write([]byte(strconv.Itoa(i)))
+
+	var part nsq.MessageID
+	hex.Encode(part[:], h.Sum(nil))
+	return part
+}
+
+// splitDelegate responds to the parent message of split parts.
+type splitDelegate struct {
+	parent   *nsq.Message
+	pending  int32
+	requeued int32
+}
+
+func (d *splitDelegate) OnFinish(m *nsq.Message) {
+	if atomic.AddInt32(&d.pending, -1) == 0 && atomic.LoadInt32(&d.requeued) == 0 {
+		d.parent.Finish()
+	}
+}
+
+func (d *splitDelegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	if !atomic.CompareAndSwapInt32(&d.requeued, 0, 1) {
+		return
+	}
+	if backoff {
+		d.parent.Requeue(delay)
+	} else {
+		d.parent.RequeueWithoutBackoff(delay)
+	}
+}
+
+func (d *splitDelegate) OnTouch(m *nsq.Message) {
+	d.parent.Touch()
+}
+
+// Processor handles the events of a topic. It is called concurrently by the
+// handlers of the topic's consumer.
+type Processor interface {
+	// Process returns the events e turns into, none to drop it. Processors
+	// turning e into several events create them with Split.
+	Process(e *Event) ([]*Event, error)
+}
+
+// Step is a named processor of a Chain.
+type Step struct {
+	Name      string
+	Processor Processor
+}
+
+// Chain runs the events of a topic through its processors in order.
+type Chain []Step
+
+// Process returns the events e turns into. When they are all dropped, it
+// returns the name of the dropping step along with the error causing it.
+func (c Chain) Process(e *Event) ([]*Event, string, error) {
+	events := []*Event{e}
+	for _, step := range c {
+		next := events[:0:0]
+		for _, e := range events {
+			out, err := step.Processor.Process(e)
+			if err != nil {
+				return nil, step.Name, err
+			}
+			if len(out) == 0 && e.part {
+				e.Message.Finish()
+			}
+			next = append(next, out...)
+		}
+		if len(next) == 0 {
+			return nil, step.Name, nil
+		}
+		events = next
+	}
+	return events, "", nil
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"os"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Reloadable is loaded from a file it reloads when it changes.
+type Reloadable interface {
+	// Reload reloads the file when it changed since it was loaded,
+	// reporting whether it did.
+	Reload() (bool, error)
+	Path() string
+}
+
+// Reload reloads the changed files every interval until done is closed.
+// Files failing to reload are logged, and used as loaded before.
+func Reload(interval time.Duration, done <-chan struct{}, files ...Reloadable) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, f := range files {
+				reloaded, err := f.Reload()
+				if err != nil {
+					log.Errorf("Reload %s fail: %v", f.Path(), err)
+					continue
+				}
+				if reloaded {
+					log.Infof("Reload %s", f.Path())
+				}
+			}
+		}
+	}
+}
+
+// fileVersion identifies the content of a file by its size and modification
+// time.
+type fileVersion struct {
+	size    int64
+	modTime time.Time
+}
+
+// readChanged reads the file at path when its version differs from v,
+// returning nil data when it is unchanged.
+func readChanged(path string, v fileVersion) ([]byte, fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, v, err
+	}
+	current := fileVersion{size: info.Size(), modTime: info.ModTime()}
+	if current.size == v.size && current.modTime.Equal(v.modTime) {
+		return nil, v, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, v, err
+	}
+	return data, current, nil
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Sampler keeps Percent of the events, chosen at random or, with a KeyField,
+// by the hash of the field so that all events of a key are kept or dropped
+// together.
+type Sampler struct {
+	Percent  float64
+	KeyField string
+
+	mux  sync.Mutex
+	rand *rand.Rand
+}
+
+func NewSampler(percent float64, keyField string) *Sampler {
+	return &Sampler{
+		Percent:  percent,
+		KeyField: keyField,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *Sampler) Process(e *Event) ([]*Event, error) {
+	if s.sample(e) < s.Percent {
+		return []*Event{e}, nil
+	}
+	return nil, nil
+}
+
+// sample returns a value in [0, 100) the percent is compared to.
+func (s *Sampler) sample(e *Event) float64 {
+	if s.KeyField == "" {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		return s.rand.Float64() * 100
+	}
+
+	var key interface{}
+	if fields, err := e.Fields(); err == nil {
+		key, _ = message.Lookup(fields, s.KeyField)
+	}
+	h := fnv.New64a()
+	fmt.Fprint(h, key)
+	return float64(h.Sum64()%10000) / 100
+}
+
+// RateLimiter drops the events exceeding Rate per second, allowing bursts of
+// Burst events.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mux    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *RateLimiter) Process(e *Event) ([]*Event, error) {
+	if l.allow(time.Now()) {
+		return []*Event{e}, nil
+	}
+	return nil, nil
+}
+
+// allow takes a token from the bucket, refilled at rate tokens per second.
+func (l *RateLimiter) allow(now time.Time) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func TestSamplerPercent(t *testing.T) {
+	const n = 10000
+	d := outputstest.NewDelegate()
+	bodies := make([]string, n)
+	for i := range bodies {
+		bodies[i] = fmt.Sprintf(`{"user":%d}`, i)
+	}
+	msgList := newMessages(d, "logs", bodies...)
+
+	tests := []struct {
+		percent  float64
+		keyField string
+		min, max int
+	}{
+		{percent: 0, max: 0},
+		{percent: 100, min: n, max: n},
+		{percent: 10, min: n / 20, max: n * 3 / 20},
+		{percent: 10, keyField: "user", min: n / 20, max: n * 3 / 20},
+	}
+	for _, tt := range tests {
+		s := NewSampler(tt.percent, tt.keyField)
+		kept := 0
+		for _, m := range msgList {
+			if passes(t, s, "logs", m) {
+				kept++
+			}
+		}
+		if kept < tt.min || kept > tt.max {
+			t.Errorf("percent %v key %q kept %d of %d, want %d to %d", tt.percent, tt.keyField, kept, n, tt.min, tt.max)
+		}
+	}
+}
+
+func TestSamplerKeyField(t *testing.T) {
+	s := NewSampler(50, "user.id")
+	d := outputstest.NewDelegate()
+	for i := 0; i < 20; i++ {
+		msgList := newMessages(d, "logs",
+			fmt.Sprintf(`{"user":{"id":%d},"n":1}`, i),
+			fmt.Sprintf(`{"user":{"id":%d},"n":2}`, i),
+		)
+		first, second := passes(t, s, "logs", msgList[0]), passes(t, s, "logs", msgList[1])
+		if first != second {
+			t.Errorf("user %d kept %v then %v, want the same for every event of a key", i, first, second)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(10, 3)
+	now := l.last
+
+	// The burst is allowed at once, then a token every 100ms.
+	for i := 0; i < 3; i++ {
+		if !l.allow(now) {
+			t.Fatalf("event %d of the burst dropped", i)
+		}
+	}
+	if l.allow(now) {
+		t.Error("event beyond the burst allowed")
+	}
+	if l.allow(now.Add(50 * time.Millisecond)) {
+		t.Error("event allowed before a token was refilled")
+	}
+	if !l.allow(now.Add(100 * time.Millisecond)) {
+		t.Error("event dropped after a token was refilled")
+	}
+	if l.allow(now.Add(100 * time.Millisecond)) {
+		t.Error("second event allowed on one refilled token")
+	}
+
+	// Idle time refills at most the burst.
+	later := now.Add(time.Hour)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.allow(later) {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d events after an hour idle, want the burst of 3", allowed)
+	}
+}
+
+func TestRateLimiterMinBurst(t *testing.T) {
+	l := NewRateLimiter(1, 0)
+	now := l.last
+	if !l.allow(now) || l.allow(now) {
+		t.Error("burst below 1 not raised to 1")
+	}
+}
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Actions on the events failing their schema.
+const (
+	SchemaDrop       = "drop"
+	SchemaDeadLetter = "dead-letter"
+	SchemaTag        = "tag"
+)
+
+// Schemas holds the compiled JSON schemas of the topics. Schemas can be
+// replaced while events are validated.
+type Schemas struct {
+	mux     sync.RWMutex
+	schemas map[string]*jsonschema.Schema
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{
+		schemas: make(map[string]*jsonschema.Schema),
+	}
+}
+
+// CompileSchema compiles the JSON schema document of topic.
+func CompileSchema(topic string, data []byte) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	url := "schema:///" + topic + ".json"
+	if err := compiler.AddResource(url, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// Set compiles and replaces the schema of topic, keeping the current one
+// when it is invalid.
+func (s *Schemas) Set(topic string, data []byte) error {
+	schema, err := CompileSchema(topic, data)
+	if err != nil {
+		return fmt.Errorf("compile schema of %s fail: %w", topic, err)
+	}
+
+	s.mux.Lock()
+	s.schemas[topic] = schema
+	s.mux.Unlock()
+	return nil
+}
+
+// Delete removes the schema of topic, so that its events are not validated.
+func (s *Schemas) Delete(topic string) {
+	s.mux.Lock()
+	delete(s.schemas, topic)
+	s.mux.Unlock()
+}
+
+func (s *Schemas) get(topic string) *jsonschema.Schema {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.schemas[topic]
+}
+
+// DeadLetterFunc sends a message to the dead letter sink and finishes it.
+type DeadLetterFunc func(m *message.Message, reason string)
+
+// Validator checks the events of a topic against its schema, passing the
+// events of topics without one.
+type Validator struct {
+	schemas    *Schemas
+	action     string
+	tagField   string
+	deadLetter DeadLetterFunc
+}
+
+// NewValidator returns a Validator handling invalid events with action. The
+// tag action passes them with the validation error in tagField, deadLetter is
+// used by the dead-letter action.
+func NewValidator(schemas *Schemas, action, tagField string, deadLetter DeadLetterFunc) *Validator {
+	return &Validator{
+		schemas:    schemas,
+		action:     action,
+		tagField:   tagField,
+		deadLetter: deadLetter,
+	}
+}
+
+func (v *Validator) Process(e *Event) ([]*Event, error) {
+	schema := v.schemas.get(e.Topic)
+	if schema == nil {
+		return []*Event{e}, nil
+	}
+
+	fields, err := e.Fields()
+	if err != nil {
+		return nil, err
+	}
+	verr := schema.Validate(fields)
+	if verr == nil {
+		return []*Event{e}, nil
+	}
+
+	switch v.action {
+	case SchemaTag:
+		fields[v.tagField] = verr.Error()
+		if err := e.SetFields(fields); err != nil {
+			return nil, err
+		}
+		return []*Event{e}, nil
+	case SchemaDeadLetter:
+		v.deadLetter(message.NewMessage(e.Message, e.Topic), verr.Error())
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
+
+// ReadSchemas reads the schema documents of the *.json files of dir, each
+// file named after its topic.
+func ReadSchemas(dir string) (map[string][]byte, error) {
+	return readTopicFiles(dir, ".json")
+}
+
+// readTopicFiles reads the files of dir with suffix, keyed by their name
+// without it.
+func readTopicFiles(dir, suffix string) (map[string][]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		contents[strings.TrimSuffix(filepath.Base(file), suffix)] = data
+	}
+	return contents, nil
+}
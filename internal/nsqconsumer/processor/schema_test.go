@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["level"],
+	"properties": {"level": {"enum": ["info", "error"]}}
+}`
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		action       string
+		body         string
+		wantBody     string
+		deadLettered bool
+	}{
+		{action: SchemaDrop, body: `{"level":"info"}`, wantBody: `{"level":"info"}`},
+		{action: SchemaDrop, body: `{"level":"debug"}`},
+		{action: SchemaDeadLetter, body: `{"msg":"x"}`, deadLettered: true},
+		{action: SchemaTag, body: `{"msg":"x"}`, wantBody: `{"_schema_error":"jsonschema: '' does not validate with schema:///logs.json#/required: missing properties: 'level'","msg":"x"}`},
+	}
+	schemas := NewSchemas()
+	if err := schemas.Set("logs", []byte(testSchema)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.body, func(t *testing.T) {
+			var deadLettered bool
+			v := NewValidator(schemas, tt.action, "_schema_error", func(m *message.Message, reason string) {
+				deadLettered = true
+			})
+			d := outputstest.NewDelegate()
+			m := newMessages(d, "logs", tt.body)[0]
+
+			events, err := v.Process(NewEvent("logs", m))
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if deadLettered != tt.deadLettered {
+				t.Errorf("dead-lettered = %v, want %v", deadLettered, tt.deadLettered)
+			}
+			var body string
+			if len(events) > 0 {
+				body = string(events[0].Message.Body)
+			}
+			if body != tt.wantBody {
+				t.Errorf("event = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidatorWithoutSchema(t *testing.T) {
+	schemas := NewSchemas()
+	if err := schemas.Set("logs", []byte(testSchema)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	v := NewValidator(schemas, SchemaDrop, "", nil)
+	d := outputstest.NewDelegate()
+
+	if !passes(t, v, "other", newMessages(d, "other", `{"level":"debug"}`)[0]) {
+		t.Error("event of a topic without schema dropped")
+	}
+	schemas.Delete("logs")
+	if !passes(t, v, "logs", newMessages(d, "logs", `{"level":"debug"}`)[0]) {
+		t.Error("event of a topic whose schema was deleted dropped")
+	}
+}
+
+func TestSchemasSetInvalid(t *testing.T) {
+	schemas := NewSchemas()
+	if err := schemas.Set("logs", []byte(testSchema)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := schemas.Set("logs", []byte(`{"type": 1}`)); err == nil {
+		t.Error("Set of an invalid schema succeeded")
+	}
+	v := NewValidator(schemas, SchemaDrop, "", nil)
+	d := outputstest.NewDelegate()
+	if passes(t, v, "logs", newMessages(d, "logs", `{"level":"debug"}`)[0]) {
+		t.Error("invalid event passes after a failed Set, want the previous schema kept")
+	}
+}
+
+func TestReadSchemas(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"logs.json": testSchema, "notes.txt": "x"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := ReadSchemas(dir)
+	if err != nil {
+		t.Fatalf("ReadSchemas: %v", err)
+	}
+	if want := map[string][]byte{"logs": []byte(testSchema)}; !reflect.DeepEqual(schemas, want) {
+		t.Errorf("ReadSchemas = %q, want %q", schemas, want)
+	}
+}
@@ -0,0 +1,270 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"go.starlark.net/starlark"
+)
+
+// scriptFunc is the function scripts define to process events.
+const scriptFunc = "process"
+
+// Scripts holds the compiled Starlark scripts of the topics. A script defines
+// process(event, meta), called with the decoded event as a dict and the
+// topic, id, timestamp and attempts of its message in meta. It returns the
+// event, which it may modify, a list of events to split it into, or None to
+// drop it. Scripts can't load modules or access anything outside the event,
+// and can be replaced while events are processed.
+type Scripts struct {
+	timeout time.Duration
+
+	mux     sync.RWMutex
+	scripts map[string]starlark.Callable
+}
+
+// NewScripts returns Scripts running each call for at most timeout.
+func NewScripts(timeout time.Duration) *Scripts {
+	return &Scripts{
+		timeout: timeout,
+		scripts: make(map[string]starlark.Callable),
+	}
+}
+
+func (s *Scripts) thread(topic string) (*starlark.Thread, *time.Timer) {
+	thread := &starlark.Thread{
+		Name: topic,
+		Print: func(thread *starlark.Thread, msg string) {
+			log.Debugf("script %s: %s", thread.Name, msg)
+		},
+	}
+	timer := time.AfterFunc(s.timeout, func() {
+		thread.Cancel(fmt.Sprintf("timeout after %s", s.timeout))
+	})
+	return thread, timer
+}
+
+// noPredeclared leaves scripts only the builtins of the language.
+func noPredeclared(string) bool {
+	return false
+}
+
+// Set compiles and replaces the script of topic, keeping the current one
+// when it is invalid.
+func (s *Scripts) Set(topic string, src []byte) error {
+	_, program, err := starlark.SourceProgram(topic+".star", src, noPredeclared)
+	if err != nil {
+		return fmt.Errorf("compile script of %s fail: %w", topic, err)
+	}
+
+	thread, timer := s.thread(topic)
+	globals, err := program.Init(thread, nil)
+	timer.Stop()
+	if err != nil {
+		return fmt.Errorf("init script of %s fail: %w", topic, err)
+	}
+	globals.Freeze()
+	fn, ok := globals[scriptFunc].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("script of %s does not define %s(event, meta)", topic, scriptFunc)
+	}
+
+	s.mux.Lock()
+	s.scripts[topic] = fn
+	s.mux.Unlock()
+	return nil
+}
+
+// Delete removes the script of topic, so that its events pass as they are.
+func (s *Scripts) Delete(topic string) {
+	s.mux.Lock()
+	delete(s.scripts, topic)
+	s.mux.Unlock()
+}
+
+func (s *Scripts) get(topic string) starlark.Callable {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.scripts[topic]
+}
+
+// ReadScripts reads the scripts of the *.star files of dir, each file named
+// after its topic.
+func ReadScripts(dir string) (map[string][]byte, error) {
+	return readTopicFiles(dir, ".star")
+}
+
+// Scripter runs the events of a topic through its script, passing the events
+// of topics without one.
+type Scripter struct {
+	scripts *Scripts
+}
+
+func NewScripter(scripts *Scripts) *Scripter {
+	return &Scripter{scripts: scripts}
+}
+
+func (p *Scripter) Process(e *Event) ([]*Event, error) {
+	fn := p.scripts.get(e.Topic)
+	if fn == nil {
+		return []*Event{e}, nil
+	}
+
+	fields, err := e.Fields()
+	if err != nil {
+		return nil, err
+	}
+	event, err := toStarlark(fields)
+	if err != nil {
+		return nil, err
+	}
+	meta := starlark.NewDict(4)
+	meta.SetKey(starlark.String("topic"), starlark.String(e.Topic))
+	meta.SetKey(starlark.String("id"), starlark.String(e.Message.ID[:]))
+	meta.SetKey(starlark.String("timestamp"), starlark.MakeInt64(e.Message.Timestamp))
+	meta.SetKey(starlark.String("attempts"), starlark.MakeInt(int(e.Message.Attempts)))
+	meta.Freeze()
+
+	thread, timer := p.scripts.thread(e.Topic)
+	result, err := starlark.Call(thread, fn, starlark.Tuple{event, meta}, nil)
+	timer.Stop()
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldsList []map[string]interface{}
+	switch result := result.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlark.Dict:
+		fields, err := fromStarlarkDict(result)
+		if err != nil {
+			return nil, err
+		}
+		fieldsList = append(fieldsList, fields)
+	case *starlark.List:
+		for i := 0; i < result.Len(); i++ {
+			d, ok := result.Index(i).(*starlark.Dict)
+			if !ok {
+				return nil, fmt.Errorf("%s returned a list of %s, want dicts", scriptFunc, result.Index(i).Type())
+			}
+			fields, err := fromStarlarkDict(d)
+			if err != nil {
+				return nil, err
+			}
+			fieldsList = append(fieldsList, fields)
+		}
+	default:
+		return nil, fmt.Errorf("%s returned %s, want dict, list or None", scriptFunc, result.Type())
+	}
+
+	switch len(fieldsList) {
+	case 0:
+		return nil, nil
+	case 1:
+		if err := e.SetFields(fieldsList[0]); err != nil {
+			return nil, err
+		}
+		return []*Event{e}, nil
+	default:
+		return e.Split(fieldsList)
+	}
+}
+
+// toStarlark converts a decoded json value, with integral numbers as ints.
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	case []interface{}:
+		elems := make([]starlark.Value, 0, len(v))
+		for _, e := range v {
+			value, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, value)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(v))
+		for _, k := range keys {
+			value, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+			d.SetKey(starlark.String(k), value)
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unsupported json value %T", v)
+	}
+}
+
+func fromStarlarkDict(d *starlark.Dict) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, d.Len())
+	for _, item := range d.Items() {
+		k, ok := item[0].(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("event key %s is not a string", item[0])
+		}
+		value, err := fromStarlark(item[1])
+		if err != nil {
+			return nil, err
+		}
+		fields[string(k)] = value
+	}
+	return fields, nil
+}
+
+// fromStarlark converts a value returned by a script to json.
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return nil, fmt.Errorf("int %s out of range", v)
+	case starlark.Float:
+		return float64(v), nil
+	case *starlark.Dict:
+		return fromStarlarkDict(v)
+	case starlark.Indexable:
+		// Lists and tuples.
+		values := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %s in event", v.Type())
+	}
+}
@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func newScripter(t *testing.T, timeout time.Duration, src string) *Scripter {
+	t.Helper()
+	scripts := NewScripts(timeout)
+	if err := scripts.Set("logs", []byte(src)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	return NewScripter(scripts)
+}
+
+func TestScripter(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		body string
+		want []string
+	}{
+		{
+			name: "modify",
+			src: `
+def process(event, meta):
+    event["topic"] = meta["topic"]
+    event["n"] = event["n"] + 1
+    event.pop("drop_me")
+    return event
+`,
+			body: `{"n":1,"f":1.5,"drop_me":true,"tags":["a"]}`,
+			want: []string{`{"f":1.5,"n":2,"tags":["a"],"topic":"logs"}`},
+		},
+		{
+			name: "drop",
+			src: `
+def process(event, meta):
+    if event.get("level") == "debug":
+        return None
+    return event
+`,
+			body: `{"level":"debug"}`,
+		},
+		{
+			name: "split",
+			src: `
+def process(event, meta):
+    return [{"item": item} for item in event["items"]]
+`,
+			body: `{"items":[1,2]}`,
+			want: []string{`{"item":1}`, `{"item":2}`},
+		},
+		{
+			name: "empty list",
+			src: `
+def process(event, meta):
+    return []
+`,
+			body: `{"items":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newScripter(t, time.Second, tt.src)
+			d := outputstest.NewDelegate()
+			m := newMessages(d, "logs", tt.body)[0]
+
+			events, err := p.Process(NewEvent("logs", m))
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("Process = %d events, want %d", len(events), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := string(events[i].Message.Body); got != want {
+					t.Errorf("event %d = %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScripterSplitResponse(t *testing.T) {
+	p := newScripter(t, time.Second, `
+def process(event, meta):
+    return [{"item": item} for item in event["items"]]
+`)
+	d := outputstest.NewDelegate()
+	msgList := d.Messages("logs", `{"items":[1,2]}`, `{"items":[1,2]}`)
+
+	events, err := p.Process(NewEvent("logs", msgList[0].GetData()))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Process = %d events, %v, want 2", len(events), err)
+	}
+	events[0].Message.Finish()
+	if got := d.Response(msgList[0]); got != outputstest.None {
+		t.Errorf("response = %d after finishing one part, want none", got)
+	}
+	events[1].Message.Finish()
+	if got := d.Response(msgList[0]); got != outputstest.Finished {
+		t.Errorf("response = %d after finishing all parts, want finished", got)
+	}
+
+	events, err = p.Process(NewEvent("logs", msgList[1].GetData()))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Process = %d events, %v, want 2", len(events), err)
+	}
+	events[0].Message.Finish()
+	events[1].Message.Requeue(-1)
+	if got := d.Response(msgList[1]); got != outputstest.Requeued {
+		t.Errorf("response = %d after requeueing a part, want requeued", got)
+	}
+}
+
+func TestScripterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "runtime error",
+			src: `
+def process(event, meta):
+    return event["missing"]
+`,
+			want: "key \"missing\" not in dict",
+		},
+		{
+			name: "wrong result",
+			src: `
+def process(event, meta):
+    return "event"
+`,
+			want: "process returned string, want dict, list or None",
+		},
+		{
+			name: "timeout",
+			src: `
+def process(event, meta):
+    n = 0
+    for i in range(100000000):
+        n += i
+    return event
+`,
+			want: "timeout after 10ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newScripter(t, 10*time.Millisecond, tt.src)
+			d := outputstest.NewDelegate()
+
+			_, err := p.Process(NewEvent("logs", newMessages(d, "logs", `{"n":1}`)[0]))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Process error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptsSet(t *testing.T) {
+	scripts := NewScripts(time.Second)
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "syntax", src: "def process(event, meta)\n"},
+		{name: "missing process", src: "x = 1\n"},
+		{name: "load", src: "load(\"other.star\", \"f\")\ndef process(event, meta):\n    return event\n"},
+		{name: "undefined", src: "def process(event, meta):\n    return json.encode(event)\n"},
+	}
+	for _, tt := range tests {
+		if err := scripts.Set("logs", []byte(tt.src)); err == nil {
+			t.Errorf("Set of %s succeeded", tt.name)
+		}
+	}
+	if scripts.get("logs") != nil {
+		t.Error("script set after failed Sets")
+	}
+}
@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ua-parser/uap-go/uaparser"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// UserAgentParser parses user agent strings with the regexes of the
+// ua-parser project, the built-in ones or those of a regexes.yaml file.
+type UserAgentParser struct {
+	path  string
+	cache *lruCache
+
+	mux     sync.RWMutex
+	parser  *uaparser.Parser
+	version fileVersion
+}
+
+// NewUserAgentParser returns a UserAgentParser using the regexes at path,
+// the built-in ones when it is empty, and caching the parses of up to
+// cacheSize user agents.
+func NewUserAgentParser(path string, cacheSize int) (*UserAgentParser, error) {
+	p := &UserAgentParser{
+		path:  path,
+		cache: newLRUCache(cacheSize),
+	}
+	if path == "" {
+		p.parser = uaparser.NewFromSaved()
+		return p, nil
+	}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *UserAgentParser) Path() string {
+	return p.path
+}
+
+func (p *UserAgentParser) Reload() (bool, error) {
+	if p.path == "" {
+		return false, nil
+	}
+
+	p.mux.RLock()
+	version := p.version
+	p.mux.RUnlock()
+
+	data, version, err := readChanged(p.path, version)
+	if err != nil || data == nil {
+		return false, err
+	}
+	parser, err := newUAParser(data)
+	if err != nil {
+		return false, err
+	}
+
+	p.mux.Lock()
+	p.parser = parser
+	p.version = version
+	p.mux.Unlock()
+	p.cache.Purge()
+	return true, nil
+}
+
+// newUAParser compiles regexes, which panics on invalid ones.
+func newUAParser(regexes []byte) (parser *uaparser.Parser, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid regexes: %v", r)
+		}
+	}()
+	return uaparser.NewFromBytes(regexes)
+}
+
+// Parse returns the fields of ua named after the Elastic Common Schema,
+// relative to the user agent object.
+func (p *UserAgentParser) Parse(ua string) map[string]interface{} {
+	if fields, ok := p.cache.Get(ua); ok {
+		return fields.(map[string]interface{})
+	}
+
+	p.mux.RLock()
+	parser := p.parser
+	p.mux.RUnlock()
+	client := parser.Parse(ua)
+
+	fields := make(map[string]interface{})
+	add := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	add("name", client.UserAgent.Family)
+	add("version", joinVersion(client.UserAgent.Major, client.UserAgent.Minor, client.UserAgent.Patch))
+	add("os.name", client.Os.Family)
+	add("os.version", joinVersion(client.Os.Major, client.Os.Minor, client.Os.Patch, client.Os.PatchMinor))
+	add("device.name", client.Device.Family)
+	add("device.brand", client.Device.Brand)
+	add("device.model", client.Device.Model)
+	p.cache.Add(ua, fields)
+	return fields
+}
+
+// joinVersion joins the leading non empty parts of a version.
+func joinVersion(parts ...string) string {
+	for i, part := range parts {
+		if part == "" {
+			parts = parts[:i]
+			break
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// UserAgent enriches events with the browser, OS and device parsed from the
+// user agent string in a field. Events without one pass as they are.
+type UserAgent struct {
+	parser *UserAgentParser
+	field  string
+	target string
+}
+
+// NewUserAgent returns a UserAgent enriching the object at target with the
+// parse of the user agent in field.
+func NewUserAgent(parser *UserAgentParser, field, target string) *UserAgent {
+	return &UserAgent{
+		parser: parser,
+		field:  field,
+		target: target,
+	}
+}
+
+func (u *UserAgent) Process(e *Event) ([]*Event, error) {
+	fields, err := e.Fields()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := message.Lookup(fields, u.field)
+	ua, _ := value.(string)
+	if ua == "" {
+		return []*Event{e}, nil
+	}
+
+	for key, value := range u.parser.Parse(ua) {
+		message.Set(fields, u.target+"."+key, value)
+	}
+	return []*Event{e}, e.SetFields(fields)
+}
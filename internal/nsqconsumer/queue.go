@@ -0,0 +1,195 @@
+package nsqconsumer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// lane holds the queued messages of a topic.
+type lane struct {
+	consumer *Consumer
+	msgList  []*message.Message
+	paused   bool
+}
+
+// queue buffers consumed messages for the output. Topics are dequeued round
+// robin, so a busy topic can't starve the others. Pushing never blocks: once
+// the queue is full, topics holding more than their share of it are paused by
+// lowering their consumer's RDY to 0, and resumed after draining to half of
+// it once the queue has room again. In-flight messages still arrive after pausing, so the depth may exceed
+// the capacity by up to the max in flight of the paused consumers.
+type queue struct {
+	mux  sync.Mutex
+	cond *sync.Cond
+
+	capacity int
+	depth    int
+	lanes    map[string]*lane
+	topics   []string
+	next     int
+	paused   int
+	closed   bool
+}
+
+func newQueue(capacity int) *queue {
+	q := &queue{
+		capacity: capacity,
+		lanes:    make(map[string]*lane),
+	}
+	q.cond = sync.NewCond(&q.mux)
+	return q
+}
+
+// share returns the number of messages a topic may hold once the queue is
+// full.
+func (q *queue) share() int {
+	share := q.capacity / len(q.lanes)
+	if share < 1 {
+		share = 1
+	}
+	return share
+}
+
+// Push queues a message of consumer.
+func (q *queue) Push(consumer *Consumer, m *message.Message) {
+	q.mux.Lock()
+	l, ok := q.lanes[consumer.topic]
+	if !ok {
+		l = &lane{consumer: consumer}
+		q.lanes[consumer.topic] = l
+		q.topics = append(q.topics, consumer.topic)
+	}
+	l.msgList = append(l.msgList, m)
+	q.depth++
+
+	pause := !l.paused && q.depth >= q.capacity && len(l.msgList) >= q.share()
+	if pause {
+		l.paused = true
+		q.paused++
+		log.Infof("Queue is full (%d/%d), pause topic %s holding %d messages", q.depth, q.capacity, consumer.topic, len(l.msgList))
+	}
+	q.cond.Signal()
+	q.mux.Unlock()
+
+	if pause {
+		consumer.pause()
+	}
+}
+
+// pop returns the next message round robin across topics, waiting while the
+// queue is empty. It returns nil once the queue is closed and drained.
+func (q *queue) pop() *message.Message {
+	q.mux.Lock()
+	for q.depth == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.depth == 0 {
+		q.mux.Unlock()
+		return nil
+	}
+
+	var l *lane
+	for {
+		l = q.lanes[q.topics[q.next]]
+		q.next = (q.next + 1) % len(q.topics)
+		if len(l.msgList) > 0 {
+			break
+		}
+	}
+	m := l.msgList[0]
+	l.msgList[0] = nil
+	l.msgList = l.msgList[1:]
+	if len(l.msgList) == 0 {
+		l.msgList = nil
+	}
+	q.depth--
+	resumed := q.resumable()
+	q.mux.Unlock()
+
+	for _, l := range resumed {
+		l.consumer.resume()
+	}
+	return m
+}
+
+// resumable unpauses and returns the paused lanes drained to half of their
+// share once the queue has room. Every paused lane is checked, as a lane may
+// have drained while the queue was still full.
+func (q *queue) resumable() []*lane {
+	if q.paused == 0 || q.depth >= q.capacity {
+		return nil
+	}
+
+	var resumed []*lane
+	for _, topic := range q.topics {
+		l := q.lanes[topic]
+		if l.paused && len(l.msgList) <= q.share()/2 {
+			l.paused = false
+			q.paused--
+			log.Infof("Resume topic %s, queue %d/%d", topic, q.depth, q.capacity)
+			resumed = append(resumed, l)
+		}
+	}
+	return resumed
+}
+
+// Run sends the queued messages to msgChan until the queue is closed and
+// drained, then closes msgChan.
+func (q *queue) Run(msgChan chan<- *message.Message) {
+	defer close(msgChan)
+	for {
+		m := q.pop()
+		if m == nil {
+			return
+		}
+		msgChan <- m
+	}
+}
+
+// Close makes Run return after the remaining messages are sent.
+func (q *queue) Close() {
+	q.mux.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mux.Unlock()
+}
+
+// Stats returns the depth of the queue and of each topic, with the paused
+// topics marked by a trailing "*".
+func (q *queue) Stats() (int, []string) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	topics := make([]string, 0, len(q.lanes))
+	for topic, l := range q.lanes {
+		stat := topic + "=" + strconv.Itoa(len(l.msgList))
+		if l.paused {
+			stat += "*"
+		}
+		topics = append(topics, stat)
+	}
+	sort.Strings(topics)
+	return q.depth, topics
+}
+
+// Report logs the queue stats every interval until done is closed.
+func (q *queue) Report(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			depth, topics := q.Stats()
+			log.Infof("Queue depth %d/%d: %s", depth, q.capacity, strings.Join(topics, " "))
+		}
+	}
+}
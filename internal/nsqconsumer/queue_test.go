@@ -0,0 +1,110 @@
+package nsqconsumer
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+func newTestConsumer(t *testing.T, topic string) *Consumer {
+	c, err := nsq.NewConsumer(topic, "test", nsq.NewConfig())
+	if err != nil {
+		t.Fatalf("nsq.NewConsumer: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	return &Consumer{topic: topic, consumer: c, maxInFlight: 10}
+}
+
+// push queues n messages of c, with bodies numbering them from 0.
+func push(q *queue, c *Consumer, n int) {
+	for i := 0; i < n; i++ {
+		var id nsq.MessageID
+		copy(id[:], c.topic+strconv.Itoa(i))
+		q.Push(c, message.NewMessage(nsq.NewMessage(id, []byte(strconv.Itoa(i))), c.topic))
+	}
+}
+
+func checkStats(t *testing.T, q *queue, wantDepth int, want ...string) {
+	t.Helper()
+	depth, topics := q.Stats()
+	if depth != wantDepth || !reflect.DeepEqual(topics, want) {
+		t.Errorf("Stats() = %d %v, want %d %v", depth, topics, wantDepth, want)
+	}
+}
+
+func TestQueueRoundRobin(t *testing.T) {
+	q := newQueue(100)
+	a, b := newTestConsumer(t, "a"), newTestConsumer(t, "b")
+	push(q, a, 3)
+	push(q, b, 1)
+
+	want := []string{"a 0", "b 0", "a 1", "a 2"}
+	for _, w := range want {
+		m := q.pop()
+		if got := m.GetTopic() + " " + string(m.GetData().Body); got != w {
+			t.Errorf("pop() = %s, want %s", got, w)
+		}
+	}
+	checkStats(t, q, 0, "a=0", "b=0")
+}
+
+func TestQueuePauseResume(t *testing.T) {
+	q := newQueue(4)
+	a, b := newTestConsumer(t, "a"), newTestConsumer(t, "b")
+	push(q, a, 2)
+	push(q, b, 2)
+	checkStats(t, q, 4, "a=2", "b=2*")
+
+	// In-flight messages still arrive while paused.
+	push(q, b, 1)
+	checkStats(t, q, 5, "a=2", "b=3*")
+
+	q.pop() // a
+	q.pop() // b
+	checkStats(t, q, 3, "a=1", "b=2*")
+	q.pop() // a
+	q.pop() // b, drained to half of its share
+	checkStats(t, q, 1, "a=0", "b=1")
+}
+
+func TestQueueResumesDrainedLanes(t *testing.T) {
+	q := newQueue(4)
+	a, b := newTestConsumer(t, "a"), newTestConsumer(t, "b")
+	push(q, b, 2)
+	push(q, a, 2)
+	push(q, b, 1)
+	push(q, a, 10)
+	checkStats(t, q, 15, "a=12*", "b=3*")
+
+	// b drains while a keeps the queue full, and is resumed once it has room.
+	for i := 0; i < 6; i++ {
+		q.pop()
+	}
+	checkStats(t, q, 9, "a=9*", "b=0*")
+	for q.depth > 0 {
+		q.pop()
+	}
+	checkStats(t, q, 0, "a=0", "b=0")
+}
+
+func TestQueueClose(t *testing.T) {
+	q := newQueue(10)
+	a := newTestConsumer(t, "a")
+	push(q, a, 2)
+	q.Close()
+
+	msgChan := make(chan *message.Message, 10)
+	q.Run(msgChan)
+
+	var got []string
+	for m := range msgChan {
+		got = append(got, string(m.GetData().Body))
+	}
+	if want := []string{"0", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run sent %v, want %v", got, want)
+	}
+}
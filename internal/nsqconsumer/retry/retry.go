@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/deadletter"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// Requeue delay curves.
+const (
+	DelayFixed       = "fixed"
+	DelayLinear      = "linear"
+	DelayExponential = "exponential"
+)
+
+// Policy defines how the failed messages of a topic are requeued.
+type Policy struct {
+	// MaxAttempts is the number of deliveries after which a message is sent
+	// to the dead letter sink, 0 requeues it forever.
+	MaxAttempts int `config:"max_attempts" json:"max_attempts"`
+	// Delay is the curve of the requeue delay over the attempts, from
+	// BaseDelay up to MaxDelay milliseconds, uncapped when MaxDelay is 0.
+	Delay     string `config:"delay" json:"delay"`
+	BaseDelay int    `config:"base_delay" json:"base_delay"`
+	MaxDelay  int    `config:"max_delay" json:"max_delay"`
+	// Backoff makes a requeue trigger the backoff of the whole consumer.
+	Backoff bool `config:"backoff" json:"backoff"`
+}
+
+// delay returns the requeue delay of a message delivered attempts times.
+func (p *Policy) delay(attempts uint16) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	base := time.Duration(p.BaseDelay) * time.Millisecond
+	max := time.Duration(p.MaxDelay) * time.Millisecond
+	delay := base
+	switch p.Delay {
+	case DelayLinear:
+		delay = base * time.Duration(attempts)
+	case DelayExponential:
+		for i := uint16(1); i < attempts && (max <= 0 || delay < max) && delay <= math.MaxInt64/2; i++ {
+			delay *= 2
+		}
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+type Config struct {
+	Default Policy             `config:"default" json:"default"`
+	Topics  map[string]*Policy `config:"topics" json:"topics"`
+}
+
+// Requeuer requeues failed messages according to the policy of their topic,
+// sending the ones exceeding the max attempts to the dead letter sink.
+type Requeuer struct {
+	config *Config
+	sink   deadletter.Sink
+}
+
+// NewRequeuer returns a Requeuer, sink may be nil to drop the messages
+// exceeding the max attempts.
+func NewRequeuer(config *Config, sink deadletter.Sink) *Requeuer {
+	return &Requeuer{
+		config: config,
+		sink:   sink,
+	}
+}
+
+func (r *Requeuer) policy(topic string) *Policy {
+	if p, ok := r.config.Topics[topic]; ok {
+		return p
+	}
+	return &r.config.Default
+}
+
+func (r *Requeuer) Requeue(m *message.Message) {
+	p := r.policy(m.GetTopic())
+	data := m.GetData()
+	if p.MaxAttempts > 0 && int(data.Attempts) >= p.MaxAttempts {
+		r.DeadLetter(m, fmt.Sprintf("failed %d attempts", data.Attempts))
+		return
+	}
+
+	delay := p.delay(data.Attempts)
+	if p.Backoff {
+		data.Requeue(delay)
+	} else {
+		data.RequeueWithoutBackoff(delay)
+	}
+}
+
+// DeadLetter sends m to the dead letter sink and finishes it, dropping it
+// when there is no sink. It is requeued when the sink fails.
+func (r *Requeuer) DeadLetter(m *message.Message, reason string) {
+	data := m.GetData()
+	if r.sink == nil {
+		log.Errorf("Drop %s message %s: %s", m.GetTopic(), data.ID, reason)
+		data.Finish()
+		return
+	}
+
+	if err := r.sink.Send(m, reason); err != nil {
+		log.Errorf("Send %s message %s to dead letter fail: %v", m.GetTopic(), data.ID, err)
+		data.Requeue(r.policy(m.GetTopic()).delay(data.Attempts))
+		return
+	}
+	data.Finish()
+}
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		attempts uint16
+		want     time.Duration
+	}{
+		{name: "fixed", policy: Policy{Delay: DelayFixed, BaseDelay: 100, MaxDelay: 1000}, attempts: 5, want: 100 * time.Millisecond},
+		{name: "fixed first attempt", policy: Policy{Delay: DelayFixed, BaseDelay: 100}, attempts: 0, want: 100 * time.Millisecond},
+		{name: "linear", policy: Policy{Delay: DelayLinear, BaseDelay: 100, MaxDelay: 1000}, attempts: 3, want: 300 * time.Millisecond},
+		{name: "linear capped", policy: Policy{Delay: DelayLinear, BaseDelay: 100, MaxDelay: 1000}, attempts: 20, want: time.Second},
+		{name: "linear uncapped", policy: Policy{Delay: DelayLinear, BaseDelay: 100}, attempts: 20, want: 2 * time.Second},
+		{name: "exponential first attempt", policy: Policy{Delay: DelayExponential, BaseDelay: 100, MaxDelay: 1000}, attempts: 1, want: 100 * time.Millisecond},
+		{name: "exponential", policy: Policy{Delay: DelayExponential, BaseDelay: 100, MaxDelay: 1000}, attempts: 4, want: 800 * time.Millisecond},
+		{name: "exponential capped", policy: Policy{Delay: DelayExponential, BaseDelay: 100, MaxDelay: 1000}, attempts: 5, want: time.Second},
+		{name: "exponential uncapped", policy: Policy{Delay: DelayExponential, BaseDelay: 100}, attempts: 8, want: 12800 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.delay(tt.attempts); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDelayUncappedOverflow(t *testing.T) {
+	p := Policy{Delay: DelayExponential, BaseDelay: 1}
+	if got := p.delay(65535); got < time.Duration(math.MaxInt64/2) {
+		t.Errorf("delay(65535) = %v, want the largest doubling without overflow", got)
+	}
+}
+
+func TestRequeuerMaxAttempts(t *testing.T) {
+	r := NewRequeuer(&Config{
+		Default: Policy{Delay: DelayFixed, MaxAttempts: 3},
+		Topics: map[string]*Policy{
+			"once":    {Delay: DelayFixed, MaxAttempts: 1},
+			"forever": {Delay: DelayFixed},
+		},
+	}, nil)
+	d := outputstest.NewDelegate()
+	tests := []struct {
+		topic string
+		want  int
+	}{
+		{topic: "logs", want: outputstest.Requeued},
+		{topic: "once", want: outputstest.Finished},
+		{topic: "forever", want: outputstest.Requeued},
+	}
+	for _, tt := range tests {
+		m := d.Messages(tt.topic, `{}`)[0]
+		r.Requeue(m)
+		if got := d.Response(m); got != tt.want {
+			t.Errorf("%s response = %d, want %d", tt.topic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package spool
+
+type Config struct {
+	Dir         string `config:"dir" json:"dir"`
+	MaxSize     int    `config:"max_size" json:"max_size"`
+	SegmentSize int    `config:"segment_size" json:"segment_size"`
+	MaxInFlight int    `config:"max_in_flight" json:"max_in_flight"`
+}
@@ -0,0 +1,172 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+)
+
+// A segment file is a sequence of records, each a little endian uint32
+// payload length and crc32c of the payload, followed by the payload: the
+// uint16 length of the topic, the topic and the nsq message as sent by nsqd.
+const (
+	headerSize    = 8
+	segmentSuffix = ".seg"
+
+	// maxRecordSize guards against allocating a corrupt record length.
+	maxRecordSize = 256 << 20
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errChecksum = errors.New("checksum mismatch")
+)
+
+// segment is a spool file. Records are appended until it reaches the segment
+// size, and it is removed once all of them are read and acknowledged.
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+
+	// read and acked count the records handed to the output and
+	// acknowledged by it, done is set once all of them are read.
+	read  int
+	acked int
+	done  bool
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// appendRecord appends the record of m to buf.
+func appendRecord(buf *bytes.Buffer, m *message.Message) {
+	mark := buf.Len()
+	buf.Write(make([]byte, headerSize))
+
+	var topicLen [2]byte
+	binary.LittleEndian.PutUint16(topicLen[:], uint16(len(m.GetTopic())))
+	buf.Write(topicLen[:])
+	buf.WriteString(m.GetTopic())
+	m.GetData().WriteTo(buf)
+
+	b := buf.Bytes()
+	payload := b[mark+headerSize:]
+	binary.LittleEndian.PutUint32(b[mark:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(b[mark+4:], crc32.Checksum(payload, crcTable))
+}
+
+// readRecord reads a record from r, returning its size in the file.
+func readRecord(r *bufio.Reader) (string, *nsq.Message, int64, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, 0, err
+	}
+	n := binary.LittleEndian.Uint32(header[:])
+	if n > maxRecordSize {
+		return "", nil, 0, errChecksum
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+		return "", nil, 0, errChecksum
+	}
+	size := int64(headerSize + len(payload))
+
+	if len(payload) < 2 {
+		return "", nil, 0, io.ErrUnexpectedEOF
+	}
+	topicLen := int(binary.LittleEndian.Uint16(payload))
+	if len(payload) < 2+topicLen {
+		return "", nil, 0, io.ErrUnexpectedEOF
+	}
+	topic := string(payload[2 : 2+topicLen])
+	m, err := nsq.DecodeMessage(payload[2+topicLen:])
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return topic, m, size, nil
+}
+
+// recoverSegments returns the segments in dir, oldest first. Each segment is
+// truncated before its first incomplete or corrupt record, which is left by a
+// crash while writing, and empty segments are removed.
+func recoverSegments(dir string) ([]*segment, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]*segment, 0, len(files))
+	for _, file := range files {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(file), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := validSize(file)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segments = append(segments, &segment{seq: seq, path: file, size: size})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].seq < segments[j].seq
+	})
+	return segments, nil
+}
+
+// validSize returns the size of the valid records of a segment, truncating
+// the rest.
+func validSize(path string) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	r := bufio.NewReader(f)
+	for {
+		_, _, n, err := readRecord(r)
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			log.Errorf("Spool segment %s is invalid at %d/%d, truncate it: %v", path, size, info.Size(), err)
+			if err := f.Truncate(size); err != nil {
+				return 0, err
+			}
+			return size, f.Sync()
+		}
+		size += n
+	}
+}
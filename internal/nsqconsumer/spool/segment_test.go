@@ -0,0 +1,138 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// records returns the records of a message of topic for each body.
+func records(topic string, bodies ...string) [][]byte {
+	d := outputstest.NewDelegate()
+	recs := make([][]byte, 0, len(bodies))
+	for _, m := range d.Messages(topic, bodies...) {
+		var buf bytes.Buffer
+		appendRecord(&buf, m)
+		recs = append(recs, buf.Bytes())
+	}
+	return recs
+}
+
+func writeSegment(t *testing.T, dir string, seq uint64, recs ...[]byte) string {
+	t.Helper()
+	path := segmentPath(dir, seq)
+	if err := os.WriteFile(path, bytes.Join(recs, nil), 0o644); err != nil {
+		t.Fatalf("write segment: %v", err)
+	}
+	return path
+}
+
+// readAll returns the topics and bodies of the records of a segment.
+func readAll(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	defer f.Close()
+
+	var got []string
+	r := bufio.NewReader(f)
+	for {
+		topic, m, _, err := readRecord(r)
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("read record: %v", err)
+		}
+		got = append(got, topic+" "+string(m.Body))
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSegment(t, dir, 1, records("logs", `{"n":1}`, `{"n":2}`)...)
+
+	got := readAll(t, path)
+	want := []string{`logs {"n":1}`, `logs {"n":2}`}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestRecoverSegments(t *testing.T) {
+	recs := records("logs", `{"n":1}`, `{"n":2}`, `{"n":3}`)
+	valid := int64(len(recs[0]) + len(recs[1]))
+
+	corrupt := append([]byte(nil), recs[2]...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	hugeLength := append([]byte(nil), recs[2]...)
+	binary.LittleEndian.PutUint32(hugeLength, maxRecordSize+1)
+
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{name: "truncated header", tail: recs[2][:headerSize-3]},
+		{name: "truncated payload", tail: recs[2][:len(recs[2])-5]},
+		{name: "checksum mismatch", tail: corrupt},
+		{name: "corrupt length", tail: hugeLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeSegment(t, dir, 7, recs[0], recs[1], tt.tail)
+
+			segments, err := recoverSegments(dir)
+			if err != nil {
+				t.Fatalf("recoverSegments: %v", err)
+			}
+			if len(segments) != 1 || segments[0].seq != 7 || segments[0].size != valid {
+				t.Fatalf("segments = %+v, want segment 7 of %d bytes", segments, valid)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("stat segment: %v", err)
+			}
+			if info.Size() != valid {
+				t.Errorf("segment is %d bytes, want it truncated to %d", info.Size(), valid)
+			}
+			if got := readAll(t, path); len(got) != 2 {
+				t.Errorf("records = %v, want the first two", got)
+			}
+		})
+	}
+}
+
+func TestRecoverSegmentsOrderAndEmpty(t *testing.T) {
+	dir := t.TempDir()
+	rec := records("logs", `{"n":1}`)[0]
+	writeSegment(t, dir, 10, rec)
+	writeSegment(t, dir, 2, rec)
+	empty := writeSegment(t, dir, 3)
+	// A segment holding only a torn record is empty once recovered.
+	torn := writeSegment(t, dir, 4, rec[:len(rec)-1])
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := recoverSegments(dir)
+	if err != nil {
+		t.Fatalf("recoverSegments: %v", err)
+	}
+	if len(segments) != 2 || segments[0].seq != 2 || segments[1].seq != 10 {
+		t.Fatalf("segments = %+v, want 2 and 10", segments)
+	}
+	for _, path := range []string{empty, torn} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists, want it removed", path)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs"
+)
+
+const (
+	writeBatchSize = 500
+	writeInterval  = 100 * time.Millisecond
+
+	// maxRetryDelay bounds the delay of replayed messages requeued by the
+	// output without one, which grows by a second per attempt.
+	maxRetryDelay = time.Minute
+)
+
+// Client writes messages to a local spool before publishing them to the
+// output it wraps. Messages are finished on nsq once they are synced to disk,
+// and replayed from the spool to the output until it acknowledges them, so
+// they survive output outages and restarts. Delivery is at least once: after
+// a restart, segments with unacknowledged records are replayed from the start.
+type Client struct {
+	config *Config
+	output outputs.Client
+
+	mux  sync.Mutex
+	cond *sync.Cond
+	done chan struct{}
+
+	segments []*segment
+	total    int64
+	closed   bool
+
+	// reading and offset locate the next record to replay, inFlight counts
+	// the replayed messages not acknowledged yet.
+	reading  *segment
+	offset   int64
+	inFlight int
+	retries  []*message.Message
+
+	file *os.File
+	buf  bytes.Buffer
+}
+
+func NewClient(config *Config, output outputs.Client) (*Client, error) {
+	return &Client{
+		config: config,
+		output: output,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+func (c *Client) Connect() error {
+	log.Infof("connect spool: %s", c.config.Dir)
+	if err := os.MkdirAll(c.config.Dir, 0o755); err != nil {
+		return err
+	}
+	segments, err := recoverSegments(c.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	c.cond = sync.NewCond(&c.mux)
+	c.segments = segments
+	for _, seg := range segments {
+		c.total += seg.size
+	}
+	if c.total > 0 {
+		log.Infof("Replay %d bytes of %d spool segments", c.total, len(segments))
+	}
+	err = c.rollLocked()
+	c.mux.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.output.Connect()
+}
+
+func (c *Client) Close() error {
+	log.Info("Close")
+	if c.file != nil {
+		c.file.Close()
+	}
+	return c.output.Close()
+}
+
+// rollLocked starts a new segment for writing.
+func (c *Client) rollLocked() error {
+	var seq uint64 = 1
+	if len(c.segments) > 0 {
+		seq = c.segments[len(c.segments)-1].seq + 1
+	}
+	path := segmentPath(c.config.Dir, seq)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(c.config.Dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	if c.file != nil {
+		c.file.Close()
+	}
+	writing := c.writing()
+	c.file = file
+	c.segments = append(c.segments, &segment{seq: seq, path: path})
+	if writing != nil {
+		c.release(writing)
+	}
+	c.cond.Broadcast()
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writing returns the segment records are appended to.
+func (c *Client) writing() *segment {
+	if c.file == nil || len(c.segments) == 0 {
+		return nil
+	}
+	return c.segments[len(c.segments)-1]
+}
+
+// release removes seg once all its records are read and acknowledged.
+func (c *Client) release(seg *segment) {
+	if !seg.done || seg.acked < seg.read || seg == c.writing() {
+		return
+	}
+	if err := os.Remove(seg.path); err != nil {
+		log.Errorf("Remove spool segment %s fail: %v", seg.path, err)
+		return
+	}
+	c.total -= seg.size
+	for i, s := range c.segments {
+		if s == seg {
+			c.segments = append(c.segments[:i], c.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+// Publish appends the messages to the spool and finishes them once synced.
+// Messages exceeding the max size of the spool are requeued.
+func (c *Client) Publish(msgList []*message.Message) {
+	c.buf.Reset()
+	for _, m := range msgList {
+		appendRecord(&c.buf, m)
+	}
+	free, err := c.free(int64(c.buf.Len()))
+	if err != nil {
+		log.Errorf("Roll spool segment fail: %v", err)
+	}
+
+	c.buf.Reset()
+	written := make([]*message.Message, 0, len(msgList))
+	for _, m := range msgList {
+		mark := c.buf.Len()
+		appendRecord(&c.buf, m)
+		if int64(c.buf.Len()) > free {
+			c.buf.Truncate(mark)
+			m.Requeue()
+			continue
+		}
+		written = append(written, m)
+	}
+	if len(written) < len(msgList) {
+		log.Infof("Spool is full, requeue %d messages", len(msgList)-len(written))
+	}
+	if len(written) == 0 {
+		return
+	}
+
+	if err := c.write(c.buf.Bytes()); err != nil {
+		log.Errorf("Write spool fail: %v", err)
+		for _, m := range written {
+			m.Requeue()
+		}
+		return
+	}
+	for _, m := range written {
+		m.GetData().Finish()
+	}
+}
+
+// free returns the free space of the spool. When it is less than size, the
+// writing segment is rolled, so that it can be removed once it is replayed.
+func (c *Client) free(size int64) (int64, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	free := int64(c.config.MaxSize)<<20 - c.total
+	if free >= size || c.writing().size == 0 {
+		return free, nil
+	}
+	err := c.rollLocked()
+	return int64(c.config.MaxSize)<<20 - c.total, err
+}
+
+// write appends records to the writing segment and syncs it, rolling the
+// segment once it reaches the segment size.
+func (c *Client) write(b []byte) error {
+	c.mux.Lock()
+	seg := c.writing()
+	c.mux.Unlock()
+
+	_, err := c.file.Write(b)
+	if err == nil {
+		err = c.file.Sync()
+	}
+	if err != nil {
+		// Drop the partial records, so the segment stays readable.
+		c.file.Truncate(seg.size)
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	seg.size += int64(len(b))
+	c.total += int64(len(b))
+	c.cond.Broadcast()
+	if seg.size >= int64(c.config.SegmentSize)<<20 {
+		return c.rollLocked()
+	}
+	return nil
+}
+
+// next returns the segment the next record is replayed from, moving past
+// fully read segments, nil when all records are read.
+func (c *Client) next() *segment {
+	if c.reading == nil {
+		c.reading = c.segments[0]
+	}
+	for c.offset >= c.reading.size {
+		var following *segment
+		for _, seg := range c.segments {
+			if seg.seq > c.reading.seq {
+				following = seg
+				break
+			}
+		}
+		if following == nil {
+			return nil
+		}
+		c.reading.done = true
+		c.release(c.reading)
+		c.reading, c.offset = following, 0
+	}
+	return c.reading
+}
+
+// replay reads the records of the spool and sends them to msgChan until the
+// client is closed, at most MaxInFlight of them unacknowledged.
+func (c *Client) replay(msgChan chan<- *message.Message) {
+	defer close(msgChan)
+
+	var (
+		file    *os.File
+		r       *bufio.Reader
+		current *segment
+	)
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		var seg *segment
+		c.mux.Lock()
+		for !c.closed && len(c.retries) == 0 {
+			if c.inFlight < c.config.MaxInFlight {
+				if seg = c.next(); seg != nil {
+					break
+				}
+			}
+			c.cond.Wait()
+		}
+		if c.closed {
+			c.mux.Unlock()
+			return
+		}
+		if len(c.retries) > 0 {
+			m := c.retries[0]
+			c.retries = c.retries[1:]
+			c.mux.Unlock()
+			if !c.send(msgChan, m) {
+				return
+			}
+			continue
+		}
+		seg.read++
+		c.inFlight++
+		c.mux.Unlock()
+
+		if seg != current {
+			if file != nil {
+				file.Close()
+			}
+			var err error
+			if file, err = os.Open(seg.path); err != nil {
+				log.Errorf("Open spool segment %s fail: %v", seg.path, err)
+				c.skip(seg)
+				file, current = nil, nil
+				continue
+			}
+			r = bufio.NewReader(file)
+			current = seg
+		}
+
+		topic, m, n, err := readRecord(r)
+		if err != nil {
+			log.Errorf("Read spool segment %s fail: %v", seg.path, err)
+			c.skip(seg)
+			continue
+		}
+		c.mux.Lock()
+		c.offset += n
+		c.mux.Unlock()
+
+		m.Delegate = &delegate{client: c, seg: seg, topic: topic}
+		if !c.send(msgChan, message.NewMessage(m, topic)) {
+			return
+		}
+	}
+}
+
+// skip gives up the rest of seg after the record just counted as read.
+func (c *Client) skip(seg *segment) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	seg.read--
+	c.inFlight--
+	c.offset = seg.size
+}
+
+func (c *Client) send(msgChan chan<- *message.Message, m *message.Message) bool {
+	select {
+	case msgChan <- m:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// Run spools the messages of msgChan and replays them to the output. It
+// returns once msgChan is closed and the output has stopped, leaving the
+// unacknowledged records for the next run.
+func (c *Client) Run(msgChan <-chan *message.Message) {
+	log.Infof("spool %s publish", c.config.Dir)
+	replayChan := make(chan *message.Message)
+	replayDone := make(chan struct{})
+	go func() {
+		c.replay(replayChan)
+		close(replayDone)
+	}()
+	outputDone := make(chan struct{})
+	go func() {
+		c.output.Run(replayChan)
+		close(outputDone)
+	}()
+
+	outputs.RunBatch(msgChan, writeBatchSize, writeInterval, c.Publish)
+
+	c.mux.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mux.Unlock()
+	close(c.done)
+	<-replayDone
+	<-outputDone
+
+	// Remove the writing segment too when it is fully replayed.
+	c.mux.Lock()
+	writing := c.writing()
+	if c.reading == writing && c.offset >= writing.size {
+		writing.done = true
+	}
+	c.file.Close()
+	c.file = nil
+	c.release(writing)
+	c.mux.Unlock()
+	log.Infof("spool %s close", c.config.Dir)
+}
+
+// delegate acknowledges replayed messages to the spool.
+type delegate struct {
+	client *Client
+	seg    *segment
+	topic  string
+}
+
+func (d *delegate) OnFinish(m *nsq.Message) {
+	c := d.client
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	d.seg.acked++
+	c.inFlight--
+	c.release(d.seg)
+	c.cond.Broadcast()
+}
+
+// OnRequeue replays m again after delay, which grows with the attempts when
+// the output leaves it to nsqd.
+func (d *delegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	if delay < 0 {
+		delay = time.Duration(m.Attempts) * time.Second
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+	retry := nsq.NewMessage(m.ID, m.Body)
+	retry.Timestamp = m.Timestamp
+	retry.Attempts = m.Attempts + 1
+	retry.Delegate = d
+
+	time.AfterFunc(delay, func() {
+		c := d.client
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		c.retries = append(c.retries, message.NewMessage(retry, d.topic))
+		c.cond.Broadcast()
+	})
+}
+
+func (d *delegate) OnTouch(m *nsq.Message) {}
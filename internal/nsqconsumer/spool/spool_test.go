@@ -0,0 +1,153 @@
+package spool
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/message"
+	"github.com/JieTrancender/nsq-tool-kit/internal/nsqconsumer/outputs/outputstest"
+)
+
+// nopOutput is an output the tests read the replayed messages from.
+type nopOutput struct{}
+
+func (nopOutput) Close() error                        { return nil }
+func (nopOutput) Connect() error                      { return nil }
+func (nopOutput) Run(msgChan <-chan *message.Message) {}
+func (nopOutput) Publish(msgList []*message.Message)  {}
+
+func newTestClient(t *testing.T, config *Config) *Client {
+	config.Dir = t.TempDir()
+	if config.MaxInFlight == 0 {
+		config.MaxInFlight = 100
+	}
+	c, err := NewClient(config, nopOutput{})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// replay replays the spool of c to the returned channel until the test ends.
+func replay(t *testing.T, c *Client) <-chan *message.Message {
+	msgChan := make(chan *message.Message)
+	go c.replay(msgChan)
+	t.Cleanup(func() {
+		c.mux.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mux.Unlock()
+		close(c.done)
+	})
+	return msgChan
+}
+
+func receive(t *testing.T, msgChan <-chan *message.Message) *message.Message {
+	t.Helper()
+	select {
+	case m := <-msgChan:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for a replayed message")
+	}
+	return nil
+}
+
+// body returns a json body of about size bytes.
+func body(size int) string {
+	return `{"pad":"` + strings.Repeat("x", size) + `"}`
+}
+
+func TestPublishSizeLimit(t *testing.T) {
+	c := newTestClient(t, &Config{MaxSize: 1, SegmentSize: 1})
+	d := outputstest.NewDelegate()
+	bodies := make([]string, 5)
+	for i := range bodies {
+		bodies[i] = body(300 << 10)
+	}
+	msgList := d.Messages("logs", bodies...)
+
+	c.Publish(msgList)
+
+	want := []int{outputstest.Finished, outputstest.Finished, outputstest.Finished, outputstest.Requeued, outputstest.Requeued}
+	for i, m := range msgList {
+		if got := d.Response(m); got != want[i] {
+			t.Errorf("message %d response = %d, want %d", i, got, want[i])
+		}
+	}
+	if c.total > 1<<20 {
+		t.Errorf("spool holds %d bytes, want at most %d", c.total, 1<<20)
+	}
+
+	// The spool stays full until the spooled messages are acknowledged.
+	more := d.Messages("logs", body(300<<10))
+	c.Publish(more)
+	if got := d.Response(more[0]); got != outputstest.Requeued {
+		t.Errorf("response = %d, want requeued while the spool is full", got)
+	}
+
+	msgChan := replay(t, c)
+	for i := 0; i < 3; i++ {
+		receive(t, msgChan).GetData().Finish()
+	}
+	more = d.Messages("logs", body(300<<10))
+	c.Publish(more)
+	if got := d.Response(more[0]); got != outputstest.Finished {
+		t.Errorf("response = %d, want finished once the spool is replayed", got)
+	}
+}
+
+func TestReleaseAckedSegment(t *testing.T) {
+	c := newTestClient(t, &Config{MaxSize: 100, SegmentSize: 1})
+	d := outputstest.NewDelegate()
+
+	// The first batch fills a segment, which is rolled.
+	c.Publish(d.Messages("logs", body(400<<10), body(400<<10), body(400<<10)))
+	c.Publish(d.Messages("logs", `{"n":4}`))
+	c.mux.Lock()
+	first := c.segments[0]
+	segments := len(c.segments)
+	c.mux.Unlock()
+	if segments != 2 {
+		t.Fatalf("got %d segments, want 2", segments)
+	}
+
+	msgChan := replay(t, c)
+	replayed := make([]*message.Message, 0, 4)
+	for i := 0; i < 4; i++ {
+		replayed = append(replayed, receive(t, msgChan))
+	}
+	if got := string(replayed[3].GetData().Body); got != `{"n":4}` {
+		t.Errorf("last replayed body = %s, want the second batch", got)
+	}
+
+	// Acknowledging part of the records keeps the segment.
+	replayed[0].GetData().Finish()
+	replayed[2].GetData().Finish()
+	if _, err := os.Stat(first.path); err != nil {
+		t.Fatalf("segment removed before all its records are acked: %v", err)
+	}
+
+	// A requeued record is replayed again, and the segment released once it
+	// is acknowledged.
+	replayed[1].GetData().Requeue(0)
+	retry := receive(t, msgChan)
+	if retry.GetData().Attempts != replayed[1].GetData().Attempts+1 {
+		t.Errorf("retry attempts = %d, want %d", retry.GetData().Attempts, replayed[1].GetData().Attempts+1)
+	}
+	retry.GetData().Finish()
+	if _, err := os.Stat(first.path); !os.IsNotExist(err) {
+		t.Errorf("segment %s exists after all its records are acked: %v", first.path, err)
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.segments) != 1 || c.segments[0] == first {
+		t.Errorf("segments = %+v, want only the writing one", c.segments)
+	}
+}
@@ -0,0 +1,11 @@
+package store
+
+import "context"
+
+// ElasticsearchStore defines the index templates and ILM policies installed by
+// the elasticsearch output.
+type ElasticsearchStore interface {
+	IndexTemplates(ctx context.Context) (map[string][]byte, error)
+	ILMPolicies(ctx context.Context) (map[string][]byte, error)
+	TopicTemplate(ctx context.Context) ([]byte, error)
+}
@@ -0,0 +1,35 @@
+package etcd
+
+import (
+	"context"
+)
+
+type elasticsearch struct {
+	ds *datastore
+}
+
+func newElasticsearch(ds *datastore) *elasticsearch {
+	return &elasticsearch{ds: ds}
+}
+
+var (
+	keyIndexTemplates = "/elasticsearch/index-templates/"
+	keyILMPolicies    = "/elasticsearch/ilm-policies/"
+	keyTopicTemplate  = "/elasticsearch/topic-template"
+)
+
+func (e *elasticsearch) IndexTemplates(ctx context.Context) (map[string][]byte, error) {
+	return e.ds.List(ctx, keyIndexTemplates)
+}
+
+func (e *elasticsearch) ILMPolicies(ctx context.Context) (map[string][]byte, error) {
+	return e.ds.List(ctx, keyILMPolicies)
+}
+
+func (e *elasticsearch) TopicTemplate(ctx context.Context) ([]byte, error) {
+	values, err := e.ds.List(ctx, keyTopicTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return values[""], nil
+}
@@ -39,6 +39,18 @@ func (ds *datastore) Nsqs() store.NsqStore {
 	return newNsqs(ds)
 }
 
+func (ds *datastore) Elasticsearch() store.ElasticsearchStore {
+	return newElasticsearch(ds)
+}
+
+func (ds *datastore) Schemas() store.SchemaStore {
+	return newSchemas(ds)
+}
+
+func (ds *datastore) Scripts() store.ScriptStore {
+	return newScripts(ds)
+}
+
 // Close closes the etcd store client.
 func (ds *datastore) Close() error {
 	if ds.cli != nil {
@@ -180,6 +192,24 @@ func (ds *datastore) Get(ctx context.Context, key string) ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
+// List returns the values of the keys under prefix, keyed by the rest of the key.
+func (ds *datastore) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	nctx, cancel := context.WithTimeout(ctx, ds.requestTimeout)
+	defer cancel()
+
+	prefix = ds.GetKey(prefix)
+	resp, err := ds.cli.Get(nctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "list keys from etcd failed")
+	}
+
+	values := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		values[string(kv.Key[len(prefix):])] = kv.Value
+	}
+	return values, nil
+}
+
 // Cancel cancels etcd client.
 func (w *EtcdWatcher) Cancel() {
 	w.watcher.Close()
@@ -209,11 +239,15 @@ func (ds *datastore) Watch(
 		for wresp := range rch {
 			for _, ev := range wresp.Events {
 				key := ev.Kv.Key[len(ds.namespace):]
+				var oldvalue []byte
 				if ev.PrevKv != nil {
-					switch ev.Type {
-					case mvccpb.PUT:
-						onModify(nctx, key, ev.PrevKv.Value, ev.Kv.Value)
-					}
+					oldvalue = ev.PrevKv.Value
+				}
+				switch ev.Type {
+				case mvccpb.PUT:
+					onModify(nctx, key, oldvalue, ev.Kv.Value)
+				case mvccpb.DELETE:
+					onModify(nctx, key, oldvalue, nil)
 				}
 			}
 		}
@@ -0,0 +1,31 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+)
+
+type schemas struct {
+	ds *datastore
+}
+
+func newSchemas(ds *datastore) *schemas {
+	return &schemas{ds: ds}
+}
+
+var keySchemas = "/schemas/"
+
+func (s *schemas) Schemas(ctx context.Context) (map[string][]byte, error) {
+	return s.ds.List(ctx, keySchemas)
+}
+
+func (s *schemas) Topic(key string) (string, bool) {
+	if !strings.HasPrefix(key, keySchemas) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, keySchemas), true
+}
+
+func (s *schemas) Prefix() string {
+	return keySchemas
+}
@@ -0,0 +1,31 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+)
+
+type scripts struct {
+	ds *datastore
+}
+
+func newScripts(ds *datastore) *scripts {
+	return &scripts{ds: ds}
+}
+
+var keyScripts = "/scripts/"
+
+func (s *scripts) Scripts(ctx context.Context) (map[string][]byte, error) {
+	return s.ds.List(ctx, keyScripts)
+}
+
+func (s *scripts) Topic(key string) (string, bool) {
+	if !strings.HasPrefix(key, keyScripts) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, keyScripts), true
+}
+
+func (s *scripts) Prefix() string {
+	return keyScripts
+}
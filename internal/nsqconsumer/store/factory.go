@@ -4,12 +4,16 @@ import "context"
 
 var client Factory
 
-// EtcdModifyEventFunc defines etcd update event function handler.
+// EtcdModifyEventFunc defines etcd update event function handler. oldvalue is
+// nil when the key is created, and value is nil when it is deleted.
 type EtcdModifyEventFunc func(ctx context.Context, key, oldvalue, value []byte)
 
 // Factory defines the consumer storage interface.
 type Factory interface {
 	Nsqs() NsqStore
+	Elasticsearch() ElasticsearchStore
+	Schemas() SchemaStore
+	Scripts() ScriptStore
 	GetKey(string) string
 	Watch(context.Context, string, EtcdModifyEventFunc) error
 	Close() error
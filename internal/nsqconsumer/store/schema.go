@@ -0,0 +1,13 @@
+package store
+
+import "context"
+
+// SchemaStore defines the JSON schemas validating the events of the topics.
+type SchemaStore interface {
+	// Schemas returns the schema documents keyed by topic.
+	Schemas(ctx context.Context) (map[string][]byte, error)
+	// Topic returns the topic of a watched schema key.
+	Topic(key string) (string, bool)
+	// Prefix returns the prefix of the schema keys to watch.
+	Prefix() string
+}
@@ -0,0 +1,13 @@
+package store
+
+import "context"
+
+// ScriptStore defines the scripts processing the events of the topics.
+type ScriptStore interface {
+	// Scripts returns the script sources keyed by topic.
+	Scripts(ctx context.Context) (map[string][]byte, error)
+	// Topic returns the topic of a watched script key.
+	Topic(key string) (string, bool)
+	// Prefix returns the prefix of the script keys to watch.
+	Prefix() string
+}
@@ -0,0 +1,144 @@
+package nsqconsumer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// toucher touches the messages held by the queue and the output before nsqd
+// times them out and redelivers them. Messages are held from HandleMessage
+// until they are finished or requeued, each touched after half of the
+// msg-timeout its consumer negotiated, so consumers created after the timeout
+// changed are touched by their own. nsqd still times out messages held longer
+// than its max-msg-timeout.
+type toucher struct {
+	delegate *heldDelegate
+
+	mux  sync.Mutex
+	held map[*nsq.Message]heldMessage
+	// tick is half of the shortest touch interval held so far, shrinking
+	// it signals ticked.
+	tick   time.Duration
+	ticked chan struct{}
+}
+
+// heldMessage records the delegate a held message is responded to, when it
+// was last touched and how often it is touched.
+type heldMessage struct {
+	delegate nsq.MessageDelegate
+	touched  time.Time
+	interval time.Duration
+}
+
+func newToucher() *toucher {
+	t := &toucher{
+		held:   make(map[*nsq.Message]heldMessage),
+		ticked: make(chan struct{}, 1),
+	}
+	t.delegate = &heldDelegate{toucher: t}
+	return t
+}
+
+// heldDelegate releases messages from the toucher when they are responded,
+// passing the response on to their own delegate.
+type heldDelegate struct {
+	toucher *toucher
+}
+
+func (d *heldDelegate) OnFinish(m *nsq.Message) {
+	d.toucher.release(m).OnFinish(m)
+}
+
+func (d *heldDelegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	d.toucher.release(m).OnRequeue(m, delay, backoff)
+}
+
+// OnTouch skips messages responded since the toucher picked them.
+func (d *heldDelegate) OnTouch(m *nsq.Message) {
+	if delegate := d.toucher.delegateOf(m); delegate != nil {
+		delegate.OnTouch(m)
+	}
+}
+
+// Hold touches m until it is finished or requeued, once it is held for half
+// of msgTimeout.
+func (t *toucher) Hold(m *nsq.Message, msgTimeout time.Duration) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	interval := msgTimeout / 2
+	t.held[m] = heldMessage{delegate: m.Delegate, touched: time.Now(), interval: interval}
+	m.Delegate = t.delegate
+	if t.tick == 0 || interval/2 < t.tick {
+		t.tick = interval / 2
+		select {
+		case t.ticked <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// release stops touching m, returning its own delegate.
+func (t *toucher) release(m *nsq.Message) nsq.MessageDelegate {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	h := t.held[m]
+	delete(t.held, m)
+	return h.delegate
+}
+
+func (t *toucher) delegateOf(m *nsq.Message) nsq.MessageDelegate {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.held[m].delegate
+}
+
+// Run touches the messages held longer than their interval until done is
+// closed.
+func (t *toucher) Run(done <-chan struct{}) {
+	var (
+		ticker   *time.Ticker
+		tickerC  <-chan time.Time
+		touching []*nsq.Message
+	)
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.ticked:
+			t.mux.Lock()
+			tick := t.tick
+			t.mux.Unlock()
+			if ticker == nil {
+				ticker = time.NewTicker(tick)
+				tickerC = ticker.C
+			} else {
+				ticker.Reset(tick)
+			}
+		case now := <-tickerC:
+			t.mux.Lock()
+			for m, h := range t.held {
+				if now.Sub(h.touched) >= h.interval {
+					h.touched = now
+					t.held[m] = h
+					touching = append(touching, m)
+				}
+			}
+			t.mux.Unlock()
+
+			for i, m := range touching {
+				m.Touch()
+				touching[i] = nil
+			}
+			touching = touching[:0]
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package nsqconsumer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// countingDelegate counts the responses to a message.
+type countingDelegate struct {
+	mu       sync.Mutex
+	touches  int
+	finishes int
+	requeues int
+}
+
+func (d *countingDelegate) OnFinish(m *nsq.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.finishes++
+}
+
+func (d *countingDelegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requeues++
+}
+
+func (d *countingDelegate) OnTouch(m *nsq.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.touches++
+}
+
+func (d *countingDelegate) counts() (touches, finishes, requeues int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.touches, d.finishes, d.requeues
+}
+
+func newHeldMessage(id string) (*nsq.Message, *countingDelegate) {
+	var msgID nsq.MessageID
+	copy(msgID[:], id)
+	m := nsq.NewMessage(msgID, []byte(`{}`))
+	d := &countingDelegate{}
+	m.Delegate = d
+	return m, d
+}
+
+func runToucher(t *testing.T) *toucher {
+	tc := newToucher()
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go tc.Run(done)
+	return tc
+}
+
+func heldCount(tc *toucher) int {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	return len(tc.held)
+}
+
+func TestToucherTouchesUntilFinished(t *testing.T) {
+	tc := runToucher(t)
+	m, d := newHeldMessage("finished")
+	tc.Hold(m, 40*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	if touches, _, _ := d.counts(); touches < 2 {
+		t.Fatalf("touches = %d after 150ms with a 40ms msg-timeout, want at least 2", touches)
+	}
+
+	m.Finish()
+	touches, finishes, _ := d.counts()
+	if finishes != 1 {
+		t.Errorf("finishes = %d, want 1", finishes)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if after, _, _ := d.counts(); after != touches {
+		t.Errorf("touches = %d after finishing, want %d", after, touches)
+	}
+	if n := heldCount(tc); n != 0 {
+		t.Errorf("held %d messages after finishing, want 0", n)
+	}
+}
+
+func TestToucherPassesRequeue(t *testing.T) {
+	tc := runToucher(t)
+	m, d := newHeldMessage("requeued")
+	tc.Hold(m, time.Minute)
+
+	m.Requeue(-1)
+	if _, _, requeues := d.counts(); requeues != 1 {
+		t.Errorf("requeues = %d, want 1", requeues)
+	}
+	if n := heldCount(tc); n != 0 {
+		t.Errorf("held %d messages after requeueing, want 0", n)
+	}
+}
+
+func TestToucherMsgTimeoutPerMessage(t *testing.T) {
+	tc := runToucher(t)
+	slow, slowDelegate := newHeldMessage("slow")
+	tc.Hold(slow, time.Hour)
+	fast, fastDelegate := newHeldMessage("fast")
+	tc.Hold(fast, 40*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	if touches, _, _ := slowDelegate.counts(); touches != 0 {
+		t.Errorf("touches = %d with a 1h msg-timeout, want 0", touches)
+	}
+	if touches, _, _ := fastDelegate.counts(); touches < 2 {
+		t.Errorf("touches = %d after 150ms with a 40ms msg-timeout, want at least 2", touches)
+	}
+}
@@ -0,0 +1,61 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// ClickHouseOptions defines options for the clickhouse output.
+type ClickHouseOptions struct {
+	URL               string            `json:"url" mapstructure:"url"`
+	Database          string            `json:"database" mapstructure:"database"`
+	Username          string            `json:"username" mapstructure:"username"`
+	Password          string            `json:"password" mapstructure:"password"`
+	Tables            map[string]string `json:"tables" mapstructure:"tables"`
+	BatchSize         int               `json:"batch-size" mapstructure:"batch-size"`
+	FlushInterval     int               `json:"flush-interval" mapstructure:"flush-interval"`
+	Timeout           int               `json:"timeout" mapstructure:"timeout"`
+	SkipUnknownFields bool              `json:"skip-unknown-fields" mapstructure:"skip-unknown-fields"`
+}
+
+func NewClickHouseOptions() *ClickHouseOptions {
+	return &ClickHouseOptions{
+		URL:               "http://127.0.0.1:8123",
+		Database:          "default",
+		Username:          "default",
+		Tables:            map[string]string{},
+		BatchSize:         200,
+		FlushInterval:     1,
+		Timeout:           30,
+		SkipUnknownFields: true,
+	}
+}
+
+func (o *ClickHouseOptions) Validate() []error {
+	errs := []error{}
+	if !strings.HasPrefix(o.URL, "http://") && !strings.HasPrefix(o.URL, "https://") {
+		errs = append(errs, fmt.Errorf("clickhouse url %q must start with http:// or https://", o.URL))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("clickhouse batch-size must be greater than 0"))
+	}
+	if o.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("clickhouse flush-interval must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *ClickHouseOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.URL, "clickhouse.url", o.URL, "URL of the clickhouse http interface.")
+	fs.StringVar(&o.Database, "clickhouse.database", o.Database, "Database of clickhouse tables.")
+	fs.StringVar(&o.Username, "clickhouse.username", o.Username, "Username of clickhouse.")
+	fs.StringVar(&o.Password, "clickhouse.password", o.Password, "Password of clickhouse.")
+	fs.StringToStringVar(&o.Tables, "clickhouse.tables", o.Tables, "Tables of topics, topics not listed are inserted into the table of the same name.")
+	fs.IntVar(&o.BatchSize, "clickhouse.batch-size", o.BatchSize, "Max number of rows per insert.")
+	fs.IntVar(&o.FlushInterval, "clickhouse.flush-interval", o.FlushInterval, "Insert a partial batch after flush-interval seconds.")
+	fs.IntVar(&o.Timeout, "clickhouse.timeout", o.Timeout, "Insert request timeout in seconds.")
+	fs.BoolVar(&o.SkipUnknownFields, "clickhouse.skip-unknown-fields", o.SkipUnknownFields, "Ignore json fields without a matching column.")
+}
@@ -0,0 +1,41 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// ConsoleOptions defines options for the stdout output.
+type ConsoleOptions struct {
+	Format   string   `json:"format" mapstructure:"format"`
+	Template string   `json:"template" mapstructure:"template"`
+	Fields   []string `json:"fields" mapstructure:"fields"`
+}
+
+func NewConsoleOptions() *ConsoleOptions {
+	return &ConsoleOptions{
+		Format: "json",
+	}
+}
+
+func (o *ConsoleOptions) Validate() []error {
+	errs := []error{}
+	switch o.Format {
+	case "json", "pretty":
+	case "template":
+		if o.Template == "" {
+			errs = append(errs, fmt.Errorf("console template can not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported console format %q", o.Format))
+	}
+
+	return errs
+}
+
+func (o *ConsoleOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Format, "console.format", o.Format, "Format of console lines, one of json, pretty, template.")
+	fs.StringVar(&o.Template, "console.template", o.Template, "text/template of console lines, used by the template format.")
+	fs.StringSliceVar(&o.Fields, "console.fields", o.Fields, "Dotted paths of the event fields to print, empty prints all.")
+}
@@ -0,0 +1,43 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// DeadLetterOptions defines the nsqd topic messages are sent to when they
+// can't be published to the output.
+type DeadLetterOptions struct {
+	Enabled        bool   `json:"enabled" mapstructure:"enabled"`
+	NsqdTCPAddress string `json:"nsqd-tcp-address" mapstructure:"nsqd-tcp-address"`
+	Topic          string `json:"topic" mapstructure:"topic"`
+}
+
+func NewDeadLetterOptions() *DeadLetterOptions {
+	return &DeadLetterOptions{
+		NsqdTCPAddress: "127.0.0.1:4150",
+		Topic:          "{{.Topic}}_dead_letter",
+	}
+}
+
+func (o *DeadLetterOptions) Validate() []error {
+	errs := []error{}
+	if !o.Enabled {
+		return errs
+	}
+	if o.NsqdTCPAddress == "" {
+		errs = append(errs, fmt.Errorf("dead-letter nsqd-tcp-address can not be empty"))
+	}
+	if o.Topic == "" {
+		errs = append(errs, fmt.Errorf("dead-letter topic can not be empty"))
+	}
+
+	return errs
+}
+
+func (o *DeadLetterOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "dead-letter.enabled", o.Enabled, "Publish messages exceeding the max attempts to nsqd instead of dropping them.")
+	fs.StringVar(&o.NsqdTCPAddress, "dead-letter.nsqd-tcp-address", o.NsqdTCPAddress, "TCP address of the nsqd dead letters are published to.")
+	fs.StringVar(&o.Topic, "dead-letter.topic", o.Topic, "text/template of the dead letter topic, with .Topic and .Fields available.")
+}
@@ -0,0 +1,140 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// DedupTopicOptions defines how repeated events of a topic are recognized.
+type DedupTopicOptions struct {
+	Key    string `json:"key" mapstructure:"key"`
+	Field  string `json:"field" mapstructure:"field"`
+	Window int    `json:"window" mapstructure:"window"`
+}
+
+func (o *DedupTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	switch o.Key {
+	case "", "none", "message-id", "hash":
+	case "field":
+		if o.Field == "" {
+			errs = append(errs, fmt.Errorf("dedup field of %s can not be empty", name))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported dedup key %q of %s", o.Key, name))
+	}
+	if o.Window < 0 {
+		errs = append(errs, fmt.Errorf("dedup window of %s must not be negative", name))
+	}
+
+	return errs
+}
+
+// Enabled reports whether repeated events are dropped.
+func (o *DedupTopicOptions) Enabled() bool {
+	return o.Key != "" && o.Key != "none" && o.Window > 0
+}
+
+// DedupRedisOptions defines the redis shared by the instances deduplicating a
+// channel.
+type DedupRedisOptions struct {
+	Addr     string `json:"addr" mapstructure:"addr"`
+	Password string `json:"password" mapstructure:"password"`
+	DB       int    `json:"db" mapstructure:"db"`
+	Prefix   string `json:"prefix" mapstructure:"prefix"`
+}
+
+// DedupOptions defines the dedup window of the topics, the top level
+// settings apply to topics without their own.
+type DedupOptions struct {
+	DedupTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Backend string             `json:"backend" mapstructure:"backend"`
+	MaxKeys int                `json:"max-keys" mapstructure:"max-keys"`
+	Redis   *DedupRedisOptions `json:"redis" mapstructure:"redis"`
+
+	Topics map[string]*DedupTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewDedupOptions() *DedupOptions {
+	return &DedupOptions{
+		DedupTopicOptions: DedupTopicOptions{
+			Window: 300,
+		},
+		Backend: "memory",
+		MaxKeys: 1000000,
+		Redis: &DedupRedisOptions{
+			Addr:   "127.0.0.1:6379",
+			Prefix: "nsq-tool-kit:dedup:",
+		},
+		Topics: map[string]*DedupTopicOptions{},
+	}
+}
+
+func (o *DedupOptions) Validate() []error {
+	errs := o.DedupTopicOptions.Validate("dedup")
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+	switch o.Backend {
+	case "memory":
+		if o.MaxKeys <= 0 {
+			errs = append(errs, fmt.Errorf("dedup max-keys must be greater than 0"))
+		}
+	case "redis":
+		if o.Redis.Addr == "" {
+			errs = append(errs, fmt.Errorf("dedup redis addr can not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported dedup backend %q", o.Backend))
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *DedupOptions) TopicOptions(topic string) *DedupTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.DedupTopicOptions
+	}
+
+	merged := *t
+	if merged.Key == "" {
+		merged.Key = o.Key
+	}
+	if merged.Field == "" {
+		merged.Field = o.Field
+	}
+	if merged.Window == 0 {
+		merged.Window = o.Window
+	}
+	return &merged
+}
+
+// Enabled reports whether any topic drops repeated events.
+func (o *DedupOptions) Enabled() bool {
+	if o.DedupTopicOptions.Enabled() {
+		return true
+	}
+	for topic := range o.Topics {
+		if o.TopicOptions(topic).Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *DedupOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Key, "dedup.key", o.Key, "Key of repeated events, message-id, field or hash of the body, empty disables dedup.")
+	fs.StringVar(&o.Field, "dedup.field", o.Field, "Dotted event field used as the key when dedup.key is field.")
+	fs.IntVar(&o.Window, "dedup.window", o.Window, "Seconds a key is remembered, repeats within them are dropped.")
+	fs.StringVar(&o.Backend, "dedup.backend", o.Backend, "Where the keys are kept, memory or redis to share them between instances.")
+	fs.IntVar(&o.MaxKeys, "dedup.max-keys", o.MaxKeys, "Max number of keys kept in memory, the oldest are forgotten first.")
+	fs.StringVar(&o.Redis.Addr, "dedup.redis.addr", o.Redis.Addr, "Address of the dedup redis.")
+	fs.StringVar(&o.Redis.Password, "dedup.redis.password", o.Redis.Password, "Password of the dedup redis.")
+	fs.IntVar(&o.Redis.DB, "dedup.redis.db", o.Redis.DB, "Database of the dedup redis.")
+	fs.StringVar(&o.Redis.Prefix, "dedup.redis.prefix", o.Redis.Prefix, "Prefix of the dedup keys in redis.")
+}
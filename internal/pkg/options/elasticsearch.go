@@ -1,29 +1,216 @@
 package options
 
 import (
+	"fmt"
+
 	"github.com/spf13/pflag"
 )
 
+// ElasticsearchTopicOptions defines how the messages of a topic are indexed.
+type ElasticsearchTopicOptions struct {
+	Cluster      string   `json:"cluster" mapstructure:"cluster"`
+	Index        string   `json:"index" mapstructure:"index"`
+	DataStream   string   `json:"data-stream" mapstructure:"data-stream"`
+	Pipeline     string   `json:"pipeline" mapstructure:"pipeline"`
+	RoutingField string   `json:"routing-field" mapstructure:"routing-field"`
+	DocumentID   string   `json:"document-id" mapstructure:"document-id"`
+	IDFields     []string `json:"id-fields" mapstructure:"id-fields"`
+	OpType       string   `json:"op-type" mapstructure:"op-type"`
+}
+
+func (o *ElasticsearchTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	switch o.DocumentID {
+	case "", "auto", "message-id":
+	case "field", "hash":
+		if len(o.IDFields) == 0 {
+			errs = append(errs, fmt.Errorf("elasticsearch id-fields of %s are required by document-id %s", name, o.DocumentID))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported elasticsearch document-id %q of %s", o.DocumentID, name))
+	}
+	switch o.OpType {
+	case "", "index", "create":
+	case "update":
+		if o.DocumentID == "" || o.DocumentID == "auto" {
+			errs = append(errs, fmt.Errorf("elasticsearch op-type update of %s requires a document-id", name))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported elasticsearch op-type %q of %s", o.OpType, name))
+	}
+	if o.DataStream != "" && o.OpType == "update" {
+		errs = append(errs, fmt.Errorf("elasticsearch data-stream of %s only supports op-type create", name))
+	}
+
+	return errs
+}
+
+// ElasticsearchBootstrapOptions defines the index templates and ILM policies
+// installed by the elasticsearch output.
+type ElasticsearchBootstrapOptions struct {
+	Enabled        bool   `json:"enabled" mapstructure:"enabled"`
+	Source         string `json:"source" mapstructure:"source"`
+	TemplatesDir   string `json:"templates-dir" mapstructure:"templates-dir"`
+	ILMPoliciesDir string `json:"ilm-policies-dir" mapstructure:"ilm-policies-dir"`
+	TopicTemplate  string `json:"topic-template" mapstructure:"topic-template"`
+}
+
+func (o *ElasticsearchBootstrapOptions) Validate() []error {
+	errs := []error{}
+	if !o.Enabled {
+		return errs
+	}
+	if o.Source != "file" && o.Source != "etcd" {
+		errs = append(errs, fmt.Errorf("unsupported elasticsearch bootstrap source %q", o.Source))
+	}
+
+	return errs
+}
+
+// ElasticsearchClusterOptions defines an elasticsearch cluster and how
+// messages are batched to it.
+type ElasticsearchClusterOptions struct {
+	Addrs         []string `json:"addrs" mapstructure:"addrs"`
+	Username      string   `json:"username" mapstructure:"username"`
+	Password      string   `json:"password" mapstructure:"password"`
+	BatchSize     int      `json:"batch-size" mapstructure:"batch-size"`
+	FlushInterval int      `json:"flush-interval" mapstructure:"flush-interval"`
+	QueueSize     int      `json:"queue-size" mapstructure:"queue-size"`
+}
+
+func (o *ElasticsearchClusterOptions) Validate(name string) []error {
+	errs := []error{}
+	if len(o.Addrs) == 0 {
+		errs = append(errs, fmt.Errorf("elasticsearch addrs of cluster %s are required", name))
+	}
+	if o.BatchSize < 0 || o.FlushInterval < 0 || o.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("elasticsearch batch-size, flush-interval and queue-size of cluster %s must not be negative", name))
+	}
+
+	return errs
+}
+
+// ElasticsearchDefaultCluster names the cluster of the top level elasticsearch
+// settings.
+const ElasticsearchDefaultCluster = "default"
+
 type ElasticsearchOptions struct {
-	Addrs    []string `json:"addrs" mapstructure:"addrs"`
-	Username string   `json:"username" mapstructure:"username"`
-	Password string   `json:"password" mapstructure:"password"`
+	ElasticsearchClusterOptions `json:",inline" mapstructure:",squash"`
+
+	// Clusters are additional clusters topics can select by name.
+	Clusters map[string]*ElasticsearchClusterOptions `json:"clusters" mapstructure:"clusters"`
+
+	ElasticsearchTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Topics map[string]*ElasticsearchTopicOptions `json:"topics" mapstructure:"topics"`
+
+	Bootstrap *ElasticsearchBootstrapOptions `json:"bootstrap" mapstructure:"bootstrap"`
 }
 
 func NewElasticsearchOptions() *ElasticsearchOptions {
 	return &ElasticsearchOptions{
-		Addrs:    []string{"127.0.0.1:9200"},
-		Username: "root",
-		Password: "123456",
+		ElasticsearchClusterOptions: ElasticsearchClusterOptions{
+			Addrs:         []string{"127.0.0.1:9200"},
+			Username:      "root",
+			Password:      "123456",
+			BatchSize:     100,
+			FlushInterval: 1,
+			QueueSize:     1000,
+		},
+		Clusters: map[string]*ElasticsearchClusterOptions{},
+		ElasticsearchTopicOptions: ElasticsearchTopicOptions{
+			Index:      "{topic}-%Y.%m.%d",
+			DocumentID: "auto",
+			OpType:     "index",
+		},
+		Topics: map[string]*ElasticsearchTopicOptions{},
+		Bootstrap: &ElasticsearchBootstrapOptions{
+			Source: "file",
+		},
 	}
 }
 
 func (o *ElasticsearchOptions) Validate() []error {
-	return []error{}
+	errs := o.ElasticsearchClusterOptions.Validate(ElasticsearchDefaultCluster)
+	for name, c := range o.Clusters {
+		if name == ElasticsearchDefaultCluster {
+			errs = append(errs, fmt.Errorf("elasticsearch cluster name %s is reserved for the top level cluster", name))
+			continue
+		}
+		errs = append(errs, c.Validate(name)...)
+	}
+	errs = append(errs, o.ElasticsearchTopicOptions.Validate("elasticsearch")...)
+	errs = append(errs, o.Bootstrap.Validate()...)
+	for topic, t := range o.Topics {
+		merged := o.topicOptions(t)
+		errs = append(errs, merged.Validate(topic)...)
+		if !o.hasCluster(merged.Cluster) {
+			errs = append(errs, fmt.Errorf("unknown elasticsearch cluster %q of %s", merged.Cluster, topic))
+		}
+	}
+	if !o.hasCluster(o.Cluster) {
+		errs = append(errs, fmt.Errorf("unknown elasticsearch cluster %q", o.Cluster))
+	}
+
+	return errs
+}
+
+func (o *ElasticsearchOptions) hasCluster(name string) bool {
+	if name == "" || name == ElasticsearchDefaultCluster {
+		return true
+	}
+	_, ok := o.Clusters[name]
+	return ok
+}
+
+// topicOptions returns a copy of t with empty settings taken from the defaults.
+func (o *ElasticsearchOptions) topicOptions(t *ElasticsearchTopicOptions) *ElasticsearchTopicOptions {
+	merged := *t
+	if merged.Cluster == "" {
+		merged.Cluster = o.Cluster
+	}
+	if merged.Index == "" {
+		merged.Index = o.Index
+	}
+	if merged.DataStream == "" {
+		merged.DataStream = o.DataStream
+	}
+	if merged.Pipeline == "" {
+		merged.Pipeline = o.Pipeline
+	}
+	if merged.RoutingField == "" {
+		merged.RoutingField = o.RoutingField
+	}
+	if merged.DocumentID == "" {
+		merged.DocumentID = o.DocumentID
+	}
+	if len(merged.IDFields) == 0 {
+		merged.IDFields = o.IDFields
+	}
+	if merged.OpType == "" {
+		merged.OpType = o.OpType
+	}
+	return &merged
 }
 
 func (o *ElasticsearchOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringSliceVar(&o.Addrs, "elasticsearch.addrs", o.Addrs, "Addrs of elasticsearch cluster.")
 	fs.StringVar(&o.Username, "elasticsearch.username", o.Username, "Username of elasticsearch cluster.")
 	fs.StringVar(&o.Password, "elasticsearch.password", o.Password, "Password of elasticsearch cluster.")
+	fs.IntVar(&o.BatchSize, "elasticsearch.batch-size", o.BatchSize, "Max number of messages in a bulk request.")
+	fs.IntVar(&o.FlushInterval, "elasticsearch.flush-interval", o.FlushInterval, "Seconds after which a partial batch is sent.")
+	fs.IntVar(&o.QueueSize, "elasticsearch.queue-size", o.QueueSize, "Messages buffered per cluster when elasticsearch.clusters are set, a full cluster holds up the others until it catches up.")
+	fs.StringVar(&o.Cluster, "elasticsearch.cluster", o.Cluster, "Cluster of topics without their own, default or a name of elasticsearch.clusters.")
+	fs.StringVar(&o.Index, "elasticsearch.index", o.Index, "Index name template, {topic} and strftime verbs are expanded.")
+	fs.StringVar(&o.DataStream, "elasticsearch.data-stream", o.DataStream, "Data stream name template, documents are created in it instead of index when set.")
+	fs.StringVar(&o.Pipeline, "elasticsearch.pipeline", o.Pipeline, "Ingest pipeline documents are processed by.")
+	fs.StringVar(&o.RoutingField, "elasticsearch.routing-field", o.RoutingField, "Dotted event field used as the routing value.")
+	fs.StringVar(&o.DocumentID, "elasticsearch.document-id", o.DocumentID, "Document _id strategy, one of auto, field, hash, message-id.")
+	fs.StringSliceVar(&o.IDFields, "elasticsearch.id-fields", o.IDFields, "Dotted event fields the field and hash document-id strategies use.")
+	fs.StringVar(&o.OpType, "elasticsearch.op-type", o.OpType, "Bulk operation, one of index, create (skip existing documents) and update (doc_as_upsert).")
+	fs.BoolVar(&o.Bootstrap.Enabled, "elasticsearch.bootstrap.enabled", o.Bootstrap.Enabled, "Install index templates and ILM policies on connect and for new topics.")
+	fs.StringVar(&o.Bootstrap.Source, "elasticsearch.bootstrap.source", o.Bootstrap.Source, "Where templates and policies are loaded from, one of file, etcd.")
+	fs.StringVar(&o.Bootstrap.TemplatesDir, "elasticsearch.bootstrap.templates-dir", o.Bootstrap.TemplatesDir, "Directory of composable index template json files, named after the template.")
+	fs.StringVar(&o.Bootstrap.ILMPoliciesDir, "elasticsearch.bootstrap.ilm-policies-dir", o.Bootstrap.ILMPoliciesDir, "Directory of ILM policy json files, named after the policy.")
+	fs.StringVar(&o.Bootstrap.TopicTemplate, "elasticsearch.bootstrap.topic-template", o.Bootstrap.TopicTemplate, "Index template json file installed for every topic, {topic} is replaced by the topic name.")
 }
@@ -0,0 +1,49 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// FileOptions defines options for the local file output.
+type FileOptions struct {
+	Path           string `json:"path" mapstructure:"path"`
+	Codec          string `json:"codec" mapstructure:"codec"`
+	MaxSize        int    `json:"max-size" mapstructure:"max-size"`
+	RotateInterval int    `json:"rotate-interval" mapstructure:"rotate-interval"`
+	MaxBackups     int    `json:"max-backups" mapstructure:"max-backups"`
+	Compress       bool   `json:"compress" mapstructure:"compress"`
+}
+
+func NewFileOptions() *FileOptions {
+	return &FileOptions{
+		Path:           "data/{topic}/{topic}-%Y.%m.%d.log",
+		Codec:          "json",
+		MaxSize:        100,
+		RotateInterval: 3600,
+		MaxBackups:     24,
+		Compress:       true,
+	}
+}
+
+func (o *FileOptions) Validate() []error {
+	errs := []error{}
+	if o.Path == "" {
+		errs = append(errs, fmt.Errorf("file path can not be empty"))
+	}
+	if o.Codec != "json" && o.Codec != "raw" {
+		errs = append(errs, fmt.Errorf("unsupported file codec %q", o.Codec))
+	}
+
+	return errs
+}
+
+func (o *FileOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Path, "file.path", o.Path, "Path template of output files, {topic} and strftime verbs are expanded.")
+	fs.StringVar(&o.Codec, "file.codec", o.Codec, "Codec of output files, json writes compact json lines, raw writes message bodies.")
+	fs.IntVar(&o.MaxSize, "file.max-size", o.MaxSize, "Rotate files when they grow larger than max-size megabytes, 0 disables.")
+	fs.IntVar(&o.RotateInterval, "file.rotate-interval", o.RotateInterval, "Rotate files after rotate-interval seconds, 0 disables.")
+	fs.IntVar(&o.MaxBackups, "file.max-backups", o.MaxBackups, "Number of rotated files to keep for each path, 0 keeps all.")
+	fs.BoolVar(&o.Compress, "file.compress", o.Compress, "Gzip rotated files.")
+}
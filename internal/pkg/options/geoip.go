@@ -0,0 +1,87 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// GeoIPTopicOptions defines which field of the events of a topic holds the IP
+// to enrich.
+type GeoIPTopicOptions struct {
+	Field  string `json:"field" mapstructure:"field"`
+	Target string `json:"target" mapstructure:"target"`
+}
+
+func (o *GeoIPTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	if o.Field != "" && o.Target == "" {
+		errs = append(errs, fmt.Errorf("geoip target of %s can not be empty", name))
+	}
+
+	return errs
+}
+
+// GeoIPOptions defines the MaxMind databases enriching the IPs of the topics,
+// the top level field and target apply to topics without their own.
+type GeoIPOptions struct {
+	GeoIPTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Databases      []string `json:"databases" mapstructure:"databases"`
+	CacheSize      int      `json:"cache-size" mapstructure:"cache-size"`
+	ReloadInterval int      `json:"reload-interval" mapstructure:"reload-interval"`
+
+	Topics map[string]*GeoIPTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewGeoIPOptions() *GeoIPOptions {
+	return &GeoIPOptions{
+		GeoIPTopicOptions: GeoIPTopicOptions{
+			Target: "source",
+		},
+		CacheSize:      10000,
+		ReloadInterval: 60,
+		Topics:         map[string]*GeoIPTopicOptions{},
+	}
+}
+
+func (o *GeoIPOptions) Validate() []error {
+	errs := o.GeoIPTopicOptions.Validate("geoip")
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+	if o.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("geoip cache-size must not be negative"))
+	}
+	if o.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("geoip reload-interval must be greater than 0"))
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *GeoIPOptions) TopicOptions(topic string) *GeoIPTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.GeoIPTopicOptions
+	}
+
+	merged := *t
+	if merged.Field == "" {
+		merged.Field = o.Field
+	}
+	if merged.Target == "" {
+		merged.Target = o.Target
+	}
+	return &merged
+}
+
+func (o *GeoIPOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.Databases, "geoip.databases", o.Databases, "MaxMind database files the IPs are looked up in, such as a City and an ASN database.")
+	fs.StringVar(&o.Field, "geoip.field", o.Field, "Dotted event field holding the IP, empty disables the enrichment.")
+	fs.StringVar(&o.Target, "geoip.target", o.Target, "Dotted event field the geo and as objects are added to.")
+	fs.IntVar(&o.CacheSize, "geoip.cache-size", o.CacheSize, "Number of IP lookups cached.")
+	fs.IntVar(&o.ReloadInterval, "geoip.reload-interval", o.ReloadInterval, "Seconds between the checks of the database files, which are reloaded when they change.")
+}
@@ -0,0 +1,60 @@
+package options
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// HTTPOptions defines options for the http webhook output.
+type HTTPOptions struct {
+	URL             string            `json:"url" mapstructure:"url"`
+	Method          string            `json:"method" mapstructure:"method"`
+	Headers         map[string]string `json:"headers" mapstructure:"headers"`
+	Batch           bool              `json:"batch" mapstructure:"batch"`
+	BatchSize       int               `json:"batch-size" mapstructure:"batch-size"`
+	Secret          string            `json:"secret" mapstructure:"secret"`
+	SignatureHeader string            `json:"signature-header" mapstructure:"signature-header"`
+	Timeout         int               `json:"timeout" mapstructure:"timeout"`
+	MaxRetries      int               `json:"max-retries" mapstructure:"max-retries"`
+	RetryBackoff    int               `json:"retry-backoff" mapstructure:"retry-backoff"`
+}
+
+func NewHTTPOptions() *HTTPOptions {
+	return &HTTPOptions{
+		Method:          http.MethodPost,
+		Headers:         map[string]string{},
+		BatchSize:       100,
+		SignatureHeader: "X-Signature",
+		Timeout:         10,
+		MaxRetries:      3,
+		RetryBackoff:    1,
+	}
+}
+
+func (o *HTTPOptions) Validate() []error {
+	errs := []error{}
+	if !strings.HasPrefix(o.URL, "http://") && !strings.HasPrefix(o.URL, "https://") {
+		errs = append(errs, fmt.Errorf("http url %q must start with http:// or https://", o.URL))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("http batch-size must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *HTTPOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.URL, "http.url", o.URL, "URL messages are sent to.")
+	fs.StringVar(&o.Method, "http.method", o.Method, "HTTP method of requests.")
+	fs.StringToStringVar(&o.Headers, "http.headers", o.Headers, "Extra headers of requests.")
+	fs.BoolVar(&o.Batch, "http.batch", o.Batch, "Send a batch of messages as a json array instead of one request per message.")
+	fs.IntVar(&o.BatchSize, "http.batch-size", o.BatchSize, "Max number of messages per batch.")
+	fs.StringVar(&o.Secret, "http.secret", o.Secret, "HMAC-SHA256 key used to sign request bodies, empty disables signing.")
+	fs.StringVar(&o.SignatureHeader, "http.signature-header", o.SignatureHeader, "Header carrying the request signature.")
+	fs.IntVar(&o.Timeout, "http.timeout", o.Timeout, "Request timeout in seconds.")
+	fs.IntVar(&o.MaxRetries, "http.max-retries", o.MaxRetries, "Max retries of a request on network errors, 429 and 5xx responses.")
+	fs.IntVar(&o.RetryBackoff, "http.retry-backoff", o.RetryBackoff, "Initial retry backoff in seconds, doubled on every retry.")
+}
@@ -0,0 +1,65 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// KafkaOptions defines options for the kafka output.
+type KafkaOptions struct {
+	Brokers     []string          `json:"brokers" mapstructure:"brokers"`
+	Topics      map[string]string `json:"topics" mapstructure:"topics"`
+	KeyField    string            `json:"key-field" mapstructure:"key-field"`
+	Acks        int               `json:"acks" mapstructure:"acks"`
+	Compression string            `json:"compression" mapstructure:"compression"`
+	BatchSize   int               `json:"batch-size" mapstructure:"batch-size"`
+	BatchBytes  int64             `json:"batch-bytes" mapstructure:"batch-bytes"`
+	MaxAttempts int               `json:"max-attempts" mapstructure:"max-attempts"`
+	Timeout     int               `json:"timeout" mapstructure:"timeout"`
+}
+
+func NewKafkaOptions() *KafkaOptions {
+	return &KafkaOptions{
+		Brokers:     []string{"127.0.0.1:9092"},
+		Topics:      map[string]string{},
+		Acks:        -1,
+		Compression: "snappy",
+		BatchSize:   100,
+		BatchBytes:  1048576,
+		MaxAttempts: 3,
+		Timeout:     10,
+	}
+}
+
+func (o *KafkaOptions) Validate() []error {
+	errs := []error{}
+	if len(o.Brokers) == 0 {
+		errs = append(errs, fmt.Errorf("kafka brokers can not be empty"))
+	}
+	if o.Acks < -1 || o.Acks > 1 {
+		errs = append(errs, fmt.Errorf("kafka acks must be one of -1, 0, 1"))
+	}
+	switch o.Compression {
+	case "", "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported kafka compression %q", o.Compression))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("kafka batch-size must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *KafkaOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.Brokers, "kafka.brokers", o.Brokers, "Addresses of kafka brokers.")
+	fs.StringToStringVar(&o.Topics, "kafka.topics", o.Topics, "Kafka topics of nsq topics, topics not listed are produced to the topic of the same name.")
+	fs.StringVar(&o.KeyField, "kafka.key-field", o.KeyField, "Dotted event field used as the partition key, empty distributes messages round-robin.")
+	fs.IntVar(&o.Acks, "kafka.acks", o.Acks, "Acknowledges required from replicas, -1 waits for all in-sync replicas, 1 for the leader and 0 for none.")
+	fs.StringVar(&o.Compression, "kafka.compression", o.Compression, "Compression codec, one of none, gzip, snappy, lz4, zstd.")
+	fs.IntVar(&o.BatchSize, "kafka.batch-size", o.BatchSize, "Max number of messages per produce batch.")
+	fs.Int64Var(&o.BatchBytes, "kafka.batch-bytes", o.BatchBytes, "Max size in bytes of a produce request.")
+	fs.IntVar(&o.MaxAttempts, "kafka.max-attempts", o.MaxAttempts, "Max attempts to deliver a batch before the messages are requeued.")
+	fs.IntVar(&o.Timeout, "kafka.timeout", o.Timeout, "Produce timeout in seconds.")
+}
@@ -0,0 +1,53 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// LokiOptions defines options for the loki output.
+type LokiOptions struct {
+	URL            string            `json:"url" mapstructure:"url"`
+	TenantID       string            `json:"tenant-id" mapstructure:"tenant-id"`
+	Username       string            `json:"username" mapstructure:"username"`
+	Password       string            `json:"password" mapstructure:"password"`
+	Labels         map[string]string `json:"labels" mapstructure:"labels"`
+	TimestampField string            `json:"timestamp-field" mapstructure:"timestamp-field"`
+	BatchSize      int               `json:"batch-size" mapstructure:"batch-size"`
+	Timeout        int               `json:"timeout" mapstructure:"timeout"`
+}
+
+func NewLokiOptions() *LokiOptions {
+	return &LokiOptions{
+		URL:            "http://127.0.0.1:3100",
+		Labels:         map[string]string{},
+		TimestampField: "time",
+		BatchSize:      200,
+		Timeout:        10,
+	}
+}
+
+func (o *LokiOptions) Validate() []error {
+	errs := []error{}
+	if !strings.HasPrefix(o.URL, "http://") && !strings.HasPrefix(o.URL, "https://") {
+		errs = append(errs, fmt.Errorf("loki url %q must start with http:// or https://", o.URL))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("loki batch-size must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *LokiOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.URL, "loki.url", o.URL, "Base URL of loki, batches are pushed to /loki/api/v1/push.")
+	fs.StringVar(&o.TenantID, "loki.tenant-id", o.TenantID, "Tenant sent in the X-Scope-OrgID header, empty disables it.")
+	fs.StringVar(&o.Username, "loki.username", o.Username, "Username of loki basic auth.")
+	fs.StringVar(&o.Password, "loki.password", o.Password, "Password of loki basic auth.")
+	fs.StringToStringVar(&o.Labels, "loki.labels", o.Labels, "Stream labels and the dotted event fields they are taken from, the topic label is always set.")
+	fs.StringVar(&o.TimestampField, "loki.timestamp-field", o.TimestampField, "Event field holding the event time, the nsq message time is used when missing.")
+	fs.IntVar(&o.BatchSize, "loki.batch-size", o.BatchSize, "Max number of entries per push.")
+	fs.IntVar(&o.Timeout, "loki.timeout", o.Timeout, "Push request timeout in seconds.")
+}
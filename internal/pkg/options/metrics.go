@@ -0,0 +1,22 @@
+package options
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// MetricsOptions defines where the expvar metrics are served.
+type MetricsOptions struct {
+	Address string `json:"address" mapstructure:"address"`
+}
+
+func NewMetricsOptions() *MetricsOptions {
+	return &MetricsOptions{}
+}
+
+func (o *MetricsOptions) Validate() []error {
+	return []error{}
+}
+
+func (o *MetricsOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Address, "metrics.address", o.Address, "Address serving the metrics at /debug/vars, empty disables it.")
+}
@@ -1,6 +1,8 @@
 package options
 
 import (
+	"fmt"
+
 	"github.com/spf13/pflag"
 )
 
@@ -12,6 +14,7 @@ type NsqOptions struct {
 	ReadTimeout          int      `json:"read-timeout" mapstructure:"read-timeout"`
 	WriteTimeout         int      `json:"write-timeout" mapstructure:"write-timeout"`
 	MaxInFlight          int      `json:"max-in-flight" mapstructure:"max-in-flight"`
+	MsgTimeout           int      `json:"msg-timeout" mapstructure:"msg-timeout"`
 }
 
 func NewNsqOptionsOptions() *NsqOptions {
@@ -23,11 +26,17 @@ func NewNsqOptionsOptions() *NsqOptions {
 		ReadTimeout:          60,
 		WriteTimeout:         5,
 		MaxInFlight:          200,
+		MsgTimeout:           60,
 	}
 }
 
 func (o *NsqOptions) Validate() []error {
-	return []error{}
+	errs := []error{}
+	if o.MsgTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("nsq msg-timeout must be positive, held messages are touched before it"))
+	}
+
+	return errs
 }
 
 func (o *NsqOptions) AddFlags(fs *pflag.FlagSet) {
@@ -38,4 +47,5 @@ func (o *NsqOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&o.ReadTimeout, "nsq.read-timeout", o.ReadTimeout, "Nsq read timeout in seconds.")
 	fs.IntVar(&o.WriteTimeout, "nsq.write-timeout", o.WriteTimeout, "Nsq write timeout in seconds.")
 	fs.IntVar(&o.MaxInFlight, "nsq.max-in-flight", o.MaxInFlight, "Max in flight.")
+	fs.IntVar(&o.MsgTimeout, "nsq.msg-timeout", o.MsgTimeout, "Seconds nsqd waits for a response before redelivering a message, held messages are touched before it.")
 }
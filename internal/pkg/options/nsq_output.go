@@ -0,0 +1,45 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// NsqOutputOptions defines options for republishing messages to nsqd.
+type NsqOutputOptions struct {
+	NsqdTCPAddress string `json:"nsqd-tcp-address" mapstructure:"nsqd-tcp-address"`
+	Topic          string `json:"topic" mapstructure:"topic"`
+	Defer          int    `json:"defer" mapstructure:"defer"`
+	BatchSize      int    `json:"batch-size" mapstructure:"batch-size"`
+}
+
+func NewNsqOutputOptions() *NsqOutputOptions {
+	return &NsqOutputOptions{
+		NsqdTCPAddress: "127.0.0.1:4150",
+		Topic:          "{{.Topic}}_copy",
+		BatchSize:      100,
+	}
+}
+
+func (o *NsqOutputOptions) Validate() []error {
+	errs := []error{}
+	if o.NsqdTCPAddress == "" {
+		errs = append(errs, fmt.Errorf("nsq-output nsqd-tcp-address can not be empty"))
+	}
+	if o.Topic == "" {
+		errs = append(errs, fmt.Errorf("nsq-output topic can not be empty"))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("nsq-output batch-size must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *NsqOutputOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.NsqdTCPAddress, "nsq-output.nsqd-tcp-address", o.NsqdTCPAddress, "TCP address of the nsqd messages are published to.")
+	fs.StringVar(&o.Topic, "nsq-output.topic", o.Topic, "text/template of the target topic, with .Topic and .Fields available.")
+	fs.IntVar(&o.Defer, "nsq-output.defer", o.Defer, "Defer publishing messages by defer milliseconds, 0 publishes immediately.")
+	fs.IntVar(&o.BatchSize, "nsq-output.batch-size", o.BatchSize, "Max number of messages per MultiPublish.")
+}
@@ -0,0 +1,63 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// Supported output types.
+const (
+	OutputElasticsearch = "elasticsearch"
+	OutputFile          = "file"
+	OutputConsole       = "console"
+	OutputHTTP          = "http"
+	OutputNsq           = "nsq"
+	OutputClickHouse    = "clickhouse"
+	OutputLoki          = "loki"
+	OutputS3            = "s3"
+	OutputSQL           = "sql"
+	OutputRedis         = "redis"
+	OutputKafka         = "kafka"
+)
+
+// OutputOptions selects which output consumed messages are published to.
+type OutputOptions struct {
+	Type               string `json:"type" mapstructure:"type"`
+	QueueSize          int    `json:"queue-size" mapstructure:"queue-size"`
+	QueueStatsInterval int    `json:"queue-stats-interval" mapstructure:"queue-stats-interval"`
+
+	Spool *SpoolOptions `json:"spool" mapstructure:"spool"`
+}
+
+// NewOutputOptions creates an OutputOptions publishing to elasticsearch.
+func NewOutputOptions() *OutputOptions {
+	return &OutputOptions{
+		Type:               OutputElasticsearch,
+		QueueSize:          1000,
+		QueueStatsInterval: 60,
+		Spool:              NewSpoolOptions(),
+	}
+}
+
+func (o *OutputOptions) Validate() []error {
+	errs := []error{}
+	switch o.Type {
+	case OutputElasticsearch, OutputFile, OutputConsole, OutputHTTP, OutputNsq, OutputClickHouse, OutputLoki, OutputS3, OutputSQL, OutputRedis, OutputKafka:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported output type %q", o.Type))
+	}
+	if o.QueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("output queue-size must be positive"))
+	}
+	errs = append(errs, o.Spool.Validate()...)
+
+	return errs
+}
+
+func (o *OutputOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Type, "output.type", o.Type, "Type of output, one of elasticsearch, file, console, http, nsq, clickhouse, loki, s3, sql, redis, kafka.")
+	fs.IntVar(&o.QueueSize, "output.queue-size", o.QueueSize, "Messages buffered for the output, topics holding more than their share of a full queue are paused.")
+	fs.IntVar(&o.QueueStatsInterval, "output.queue-stats-interval", o.QueueStatsInterval, "Seconds between logging the queue depth of each topic, 0 disables it.")
+	o.Spool.AddFlags(fs)
+}
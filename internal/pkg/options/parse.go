@@ -0,0 +1,90 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// ParseTopicOptions defines how the text of a topic is parsed into fields.
+type ParseTopicOptions struct {
+	Patterns     []string `json:"patterns" mapstructure:"patterns"`
+	Field        string   `json:"field" mapstructure:"field"`
+	MessageField string   `json:"message-field" mapstructure:"message-field"`
+	NoMatch      string   `json:"no-match" mapstructure:"no-match"`
+}
+
+func (o *ParseTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	switch o.NoMatch {
+	case "drop", "dead-letter", "keep":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported parse no-match %q of %s", o.NoMatch, name))
+	}
+	if o.MessageField == "" {
+		errs = append(errs, fmt.Errorf("parse message-field of %s can not be empty", name))
+	}
+
+	return errs
+}
+
+// ParseOptions defines the grok patterns parsing the text of the topics, the
+// top level settings apply to topics without their own.
+type ParseOptions struct {
+	ParseTopicOptions `json:",inline" mapstructure:",squash"`
+
+	PatternsDir string `json:"patterns-dir" mapstructure:"patterns-dir"`
+
+	Topics map[string]*ParseTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewParseOptions() *ParseOptions {
+	return &ParseOptions{
+		ParseTopicOptions: ParseTopicOptions{
+			MessageField: "message",
+			NoMatch:      "keep",
+		},
+		Topics: map[string]*ParseTopicOptions{},
+	}
+}
+
+func (o *ParseOptions) Validate() []error {
+	errs := o.ParseTopicOptions.Validate("parse")
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *ParseOptions) TopicOptions(topic string) *ParseTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.ParseTopicOptions
+	}
+
+	merged := *t
+	if len(merged.Patterns) == 0 {
+		merged.Patterns = o.Patterns
+	}
+	if merged.Field == "" {
+		merged.Field = o.Field
+	}
+	if merged.MessageField == "" {
+		merged.MessageField = o.MessageField
+	}
+	if merged.NoMatch == "" {
+		merged.NoMatch = o.NoMatch
+	}
+	return &merged
+}
+
+func (o *ParseOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.Patterns, "parse.patterns", o.Patterns, "Grok expressions parsing text into fields, the first matching one is used, empty disables parsing.")
+	fs.StringVar(&o.Field, "parse.field", o.Field, "Dotted event field holding the text, the text bodies are parsed when empty.")
+	fs.StringVar(&o.MessageField, "parse.message-field", o.MessageField, "Field holding the text of parsed text bodies.")
+	fs.StringVar(&o.NoMatch, "parse.no-match", o.NoMatch, "Action on text matching no pattern, drop, dead-letter or keep.")
+	fs.StringVar(&o.PatternsDir, "parse.patterns-dir", o.PatternsDir, "Directory of files adding grok patterns, each line a name and its pattern.")
+}
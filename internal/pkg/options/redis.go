@@ -0,0 +1,60 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// RedisOptions defines options for the redis output.
+type RedisOptions struct {
+	Addr        string `json:"addr" mapstructure:"addr"`
+	Username    string `json:"username" mapstructure:"username"`
+	Password    string `json:"password" mapstructure:"password"`
+	DB          int    `json:"db" mapstructure:"db"`
+	Mode        string `json:"mode" mapstructure:"mode"`
+	Key         string `json:"key" mapstructure:"key"`
+	StreamField string `json:"stream-field" mapstructure:"stream-field"`
+	MaxLen      int64  `json:"max-len" mapstructure:"max-len"`
+	BatchSize   int    `json:"batch-size" mapstructure:"batch-size"`
+}
+
+func NewRedisOptions() *RedisOptions {
+	return &RedisOptions{
+		Addr:        "127.0.0.1:6379",
+		Mode:        "stream",
+		Key:         "{{.Topic}}",
+		StreamField: "data",
+		MaxLen:      100000,
+		BatchSize:   100,
+	}
+}
+
+func (o *RedisOptions) Validate() []error {
+	errs := []error{}
+	switch o.Mode {
+	case "stream", "list", "pubsub":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported redis mode %q", o.Mode))
+	}
+	if o.Key == "" {
+		errs = append(errs, fmt.Errorf("redis key can not be empty"))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("redis batch-size must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *RedisOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Addr, "redis.addr", o.Addr, "Address of redis.")
+	fs.StringVar(&o.Username, "redis.username", o.Username, "Username of redis.")
+	fs.StringVar(&o.Password, "redis.password", o.Password, "Password of redis.")
+	fs.IntVar(&o.DB, "redis.db", o.DB, "Redis database.")
+	fs.StringVar(&o.Mode, "redis.mode", o.Mode, "How messages are written, stream uses XADD, list uses LPUSH and pubsub uses PUBLISH.")
+	fs.StringVar(&o.Key, "redis.key", o.Key, "text/template of the key or channel, with .Topic and .Fields available.")
+	fs.StringVar(&o.StreamField, "redis.stream-field", o.StreamField, "Stream entry field holding the message body.")
+	fs.Int64Var(&o.MaxLen, "redis.max-len", o.MaxLen, "Approximate max length streams are trimmed to, 0 disables trimming.")
+	fs.IntVar(&o.BatchSize, "redis.batch-size", o.BatchSize, "Max number of commands per pipeline.")
+}
@@ -0,0 +1,93 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// RetryTopicOptions defines how the failed messages of a topic are requeued.
+// The pointers are nil when a topic inherits the default, as 0 is a setting of
+// its own.
+type RetryTopicOptions struct {
+	MaxAttempts *int   `json:"max-attempts" mapstructure:"max-attempts"`
+	Delay       string `json:"delay" mapstructure:"delay"`
+	BaseDelay   *int   `json:"base-delay" mapstructure:"base-delay"`
+	MaxDelay    *int   `json:"max-delay" mapstructure:"max-delay"`
+	Backoff     *bool  `json:"backoff" mapstructure:"backoff"`
+}
+
+func (o *RetryTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	switch o.Delay {
+	case "fixed", "linear", "exponential":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported retry delay %q of %s", o.Delay, name))
+	}
+	if *o.MaxAttempts < 0 || *o.BaseDelay < 0 || *o.MaxDelay < 0 {
+		errs = append(errs, fmt.Errorf("retry max-attempts, base-delay and max-delay of %s must not be negative", name))
+	}
+
+	return errs
+}
+
+// RetryOptions defines the requeue policy of failed messages, overridable per
+// topic.
+type RetryOptions struct {
+	RetryTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Topics map[string]*RetryTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewRetryOptions() *RetryOptions {
+	maxAttempts, baseDelay, maxDelay, backoff := 0, 1000, 600000, true
+	return &RetryOptions{
+		RetryTopicOptions: RetryTopicOptions{
+			MaxAttempts: &maxAttempts,
+			Delay:       "exponential",
+			BaseDelay:   &baseDelay,
+			MaxDelay:    &maxDelay,
+			Backoff:     &backoff,
+		},
+		Topics: map[string]*RetryTopicOptions{},
+	}
+}
+
+func (o *RetryOptions) Validate() []error {
+	errs := o.RetryTopicOptions.Validate("retry")
+	for topic, t := range o.Topics {
+		errs = append(errs, o.TopicOptions(t).Validate(topic)...)
+	}
+
+	return errs
+}
+
+// TopicOptions returns a copy of t with unset settings taken from the
+// defaults.
+func (o *RetryOptions) TopicOptions(t *RetryTopicOptions) *RetryTopicOptions {
+	merged := *t
+	if merged.MaxAttempts == nil {
+		merged.MaxAttempts = o.MaxAttempts
+	}
+	if merged.Delay == "" {
+		merged.Delay = o.Delay
+	}
+	if merged.BaseDelay == nil {
+		merged.BaseDelay = o.BaseDelay
+	}
+	if merged.MaxDelay == nil {
+		merged.MaxDelay = o.MaxDelay
+	}
+	if merged.Backoff == nil {
+		merged.Backoff = o.Backoff
+	}
+	return &merged
+}
+
+func (o *RetryOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.IntVar(o.MaxAttempts, "retry.max-attempts", *o.MaxAttempts, "Attempts after which failed messages are sent to the dead letter sink, 0 requeues them forever.")
+	fs.StringVar(&o.Delay, "retry.delay", o.Delay, "Requeue delay curve over the attempts, one of fixed, linear, exponential.")
+	fs.IntVar(o.BaseDelay, "retry.base-delay", *o.BaseDelay, "Requeue delay of the first attempt in milliseconds.")
+	fs.IntVar(o.MaxDelay, "retry.max-delay", *o.MaxDelay, "Max requeue delay in milliseconds, 0 for no cap, bounded by the max-req-timeout of nsqd.")
+	fs.BoolVar(o.Backoff, "retry.backoff", *o.Backoff, "Whether a requeue triggers the backoff of the whole consumer.")
+}
@@ -0,0 +1,25 @@
+package options
+
+import "testing"
+
+func TestRetryTopicOptions(t *testing.T) {
+	o := NewRetryOptions()
+	*o.MaxAttempts = 5
+	zero := 0
+	o.Topics = map[string]*RetryTopicOptions{
+		"inherit":   {},
+		"unlimited": {MaxAttempts: &zero, MaxDelay: &zero},
+	}
+
+	inherit := o.TopicOptions(o.Topics["inherit"])
+	if *inherit.MaxAttempts != 5 || *inherit.MaxDelay != 600000 || inherit.Delay != "exponential" {
+		t.Errorf("inherit = max-attempts %d, max-delay %d, delay %s, want the defaults", *inherit.MaxAttempts, *inherit.MaxDelay, inherit.Delay)
+	}
+	unlimited := o.TopicOptions(o.Topics["unlimited"])
+	if *unlimited.MaxAttempts != 0 || *unlimited.MaxDelay != 0 || *unlimited.BaseDelay != 1000 {
+		t.Errorf("unlimited = max-attempts %d, max-delay %d, base-delay %d, want 0, 0, 1000", *unlimited.MaxAttempts, *unlimited.MaxDelay, *unlimited.BaseDelay)
+	}
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+}
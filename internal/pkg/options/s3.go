@@ -0,0 +1,70 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// S3Options defines options for the s3 compatible object storage output.
+type S3Options struct {
+	Endpoint      string `json:"endpoint" mapstructure:"endpoint"`
+	Region        string `json:"region" mapstructure:"region"`
+	AccessKey     string `json:"access-key" mapstructure:"access-key"`
+	SecretKey     string `json:"secret-key" mapstructure:"secret-key"`
+	UseSSL        bool   `json:"use-ssl" mapstructure:"use-ssl"`
+	Bucket        string `json:"bucket" mapstructure:"bucket"`
+	Prefix        string `json:"prefix" mapstructure:"prefix"`
+	Format        string `json:"format" mapstructure:"format"`
+	ParquetSchema string `json:"parquet-schema" mapstructure:"parquet-schema"`
+	MaxSize       int    `json:"max-size" mapstructure:"max-size"`
+	FlushInterval int    `json:"flush-interval" mapstructure:"flush-interval"`
+}
+
+func NewS3Options() *S3Options {
+	return &S3Options{
+		Endpoint:      "127.0.0.1:9000",
+		Bucket:        "nsq-archive",
+		Format:        "json",
+		MaxSize:       64,
+		FlushInterval: 30,
+	}
+}
+
+func (o *S3Options) Validate() []error {
+	errs := []error{}
+	if o.Endpoint == "" {
+		errs = append(errs, fmt.Errorf("s3 endpoint can not be empty"))
+	}
+	if o.Bucket == "" {
+		errs = append(errs, fmt.Errorf("s3 bucket can not be empty"))
+	}
+	switch o.Format {
+	case "json":
+	case "parquet":
+		if o.ParquetSchema == "" {
+			errs = append(errs, fmt.Errorf("s3 parquet-schema is required by the parquet format"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported s3 format %q", o.Format))
+	}
+	if o.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("s3 flush-interval must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *S3Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "s3.endpoint", o.Endpoint, "Endpoint of the s3 compatible storage.")
+	fs.StringVar(&o.Region, "s3.region", o.Region, "Region of the bucket.")
+	fs.StringVar(&o.AccessKey, "s3.access-key", o.AccessKey, "Access key of the storage.")
+	fs.StringVar(&o.SecretKey, "s3.secret-key", o.SecretKey, "Secret key of the storage.")
+	fs.BoolVar(&o.UseSSL, "s3.use-ssl", o.UseSSL, "Connect to the storage with https.")
+	fs.StringVar(&o.Bucket, "s3.bucket", o.Bucket, "Bucket objects are uploaded to.")
+	fs.StringVar(&o.Prefix, "s3.prefix", o.Prefix, "Prefix of object keys.")
+	fs.StringVar(&o.Format, "s3.format", o.Format, "Format of objects, json writes gzipped json lines, parquet writes parquet files.")
+	fs.StringVar(&o.ParquetSchema, "s3.parquet-schema", o.ParquetSchema, "Path of the parquet-go json schema used by the parquet format.")
+	fs.IntVar(&o.MaxSize, "s3.max-size", o.MaxSize, "Upload a partition once it holds max-size megabytes of messages.")
+	fs.IntVar(&o.FlushInterval, "s3.flush-interval", o.FlushInterval, "Upload a partition flush-interval seconds after its first message, keep it below the nsqd msg-timeout.")
+}
@@ -0,0 +1,92 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SamplingTopicOptions defines which events of a topic are kept.
+type SamplingTopicOptions struct {
+	Percent        float64 `json:"percent" mapstructure:"percent"`
+	KeyField       string  `json:"key-field" mapstructure:"key-field"`
+	RateLimit      float64 `json:"rate-limit" mapstructure:"rate-limit"`
+	Burst          int     `json:"burst" mapstructure:"burst"`
+	NsqdSampleRate int     `json:"nsqd-sample-rate" mapstructure:"nsqd-sample-rate"`
+}
+
+func (o *SamplingTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	if o.Percent < 0 || o.Percent > 100 {
+		errs = append(errs, fmt.Errorf("sampling percent of %s must be between 0 and 100", name))
+	}
+	if o.RateLimit < 0 || o.Burst < 0 {
+		errs = append(errs, fmt.Errorf("sampling rate-limit and burst of %s must not be negative", name))
+	}
+	if o.NsqdSampleRate < 0 || o.NsqdSampleRate > 99 {
+		errs = append(errs, fmt.Errorf("sampling nsqd-sample-rate of %s must be between 0 and 99", name))
+	}
+
+	return errs
+}
+
+// SamplingOptions defines the sampling and rate limits of the topics, the
+// top level settings apply to topics without their own.
+type SamplingOptions struct {
+	SamplingTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Topics map[string]*SamplingTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewSamplingOptions() *SamplingOptions {
+	return &SamplingOptions{
+		SamplingTopicOptions: SamplingTopicOptions{
+			Percent: 100,
+		},
+		Topics: map[string]*SamplingTopicOptions{},
+	}
+}
+
+func (o *SamplingOptions) Validate() []error {
+	errs := o.SamplingTopicOptions.Validate("sampling")
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *SamplingOptions) TopicOptions(topic string) *SamplingTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.SamplingTopicOptions
+	}
+
+	merged := *t
+	if merged.Percent == 0 {
+		merged.Percent = o.Percent
+	}
+	if merged.KeyField == "" {
+		merged.KeyField = o.KeyField
+	}
+	if merged.RateLimit == 0 {
+		merged.RateLimit = o.RateLimit
+	}
+	if merged.Burst == 0 {
+		merged.Burst = o.Burst
+	}
+	if merged.NsqdSampleRate == 0 {
+		merged.NsqdSampleRate = o.NsqdSampleRate
+	}
+	return &merged
+}
+
+func (o *SamplingOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.Float64Var(&o.Percent, "sampling.percent", o.Percent, "Percentage of the events kept.")
+	fs.StringVar(&o.KeyField, "sampling.key-field", o.KeyField, "Dotted event field hashed to choose the kept events deterministically, random when empty.")
+	fs.Float64Var(&o.RateLimit, "sampling.rate-limit", o.RateLimit, "Max events per second kept of each topic, 0 is unlimited.")
+	fs.IntVar(&o.Burst, "sampling.burst", o.Burst, "Events kept in a burst above the rate limit.")
+	fs.IntVar(&o.NsqdSampleRate, "sampling.nsqd-sample-rate", o.NsqdSampleRate, "Percentage of the channel's messages nsqd delivers (1-99), 0 delivers all.")
+}
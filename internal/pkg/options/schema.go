@@ -0,0 +1,102 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SchemaTopicOptions defines what happens to the events of a topic failing
+// its schema.
+type SchemaTopicOptions struct {
+	Action   string `json:"action" mapstructure:"action"`
+	TagField string `json:"tag-field" mapstructure:"tag-field"`
+}
+
+func (o *SchemaTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	switch o.Action {
+	case "drop", "dead-letter":
+	case "tag":
+		if o.TagField == "" {
+			errs = append(errs, fmt.Errorf("schema tag-field of %s can not be empty", name))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported schema action %q of %s", o.Action, name))
+	}
+
+	return errs
+}
+
+// SchemaOptions defines where the JSON schemas of the topics are loaded from
+// and the action on invalid events, the top level action applies to topics
+// without their own.
+type SchemaOptions struct {
+	SchemaTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Enabled bool   `json:"enabled" mapstructure:"enabled"`
+	Source  string `json:"source" mapstructure:"source"`
+	Dir     string `json:"dir" mapstructure:"dir"`
+
+	Topics map[string]*SchemaTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewSchemaOptions() *SchemaOptions {
+	return &SchemaOptions{
+		SchemaTopicOptions: SchemaTopicOptions{
+			Action:   "drop",
+			TagField: "_schema_error",
+		},
+		Source: "file",
+		Dir:    "conf/schemas",
+		Topics: map[string]*SchemaTopicOptions{},
+	}
+}
+
+func (o *SchemaOptions) Validate() []error {
+	errs := []error{}
+	if !o.Enabled {
+		return errs
+	}
+	switch o.Source {
+	case "file":
+		if o.Dir == "" {
+			errs = append(errs, fmt.Errorf("schema dir can not be empty"))
+		}
+	case "etcd":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported schema source %q", o.Source))
+	}
+	errs = append(errs, o.SchemaTopicOptions.Validate("schema")...)
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *SchemaOptions) TopicOptions(topic string) *SchemaTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.SchemaTopicOptions
+	}
+
+	merged := *t
+	if merged.Action == "" {
+		merged.Action = o.Action
+	}
+	if merged.TagField == "" {
+		merged.TagField = o.TagField
+	}
+	return &merged
+}
+
+func (o *SchemaOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "schema.enabled", o.Enabled, "Validate the events of the topics having a JSON schema.")
+	fs.StringVar(&o.Source, "schema.source", o.Source, "Where the schemas are loaded from, file or etcd, which reloads them when their key changes.")
+	fs.StringVar(&o.Dir, "schema.dir", o.Dir, "Directory of the {topic}.json schema files.")
+	fs.StringVar(&o.Action, "schema.action", o.Action, "Action on invalid events, drop, dead-letter or tag to pass them with the error.")
+	fs.StringVar(&o.TagField, "schema.tag-field", o.TagField, "Field set to the validation error of invalid events by the tag action.")
+}
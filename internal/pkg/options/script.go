@@ -0,0 +1,52 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// ScriptOptions defines where the Starlark scripts processing the events of
+// the topics are loaded from.
+type ScriptOptions struct {
+	Enabled bool   `json:"enabled" mapstructure:"enabled"`
+	Source  string `json:"source" mapstructure:"source"`
+	Dir     string `json:"dir" mapstructure:"dir"`
+	Timeout int    `json:"timeout" mapstructure:"timeout"`
+}
+
+func NewScriptOptions() *ScriptOptions {
+	return &ScriptOptions{
+		Source:  "file",
+		Dir:     "conf/scripts",
+		Timeout: 100,
+	}
+}
+
+func (o *ScriptOptions) Validate() []error {
+	errs := []error{}
+	if !o.Enabled {
+		return errs
+	}
+	switch o.Source {
+	case "file":
+		if o.Dir == "" {
+			errs = append(errs, fmt.Errorf("script dir can not be empty"))
+		}
+	case "etcd":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported script source %q", o.Source))
+	}
+	if o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("script timeout must be greater than 0"))
+	}
+
+	return errs
+}
+
+func (o *ScriptOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "script.enabled", o.Enabled, "Process the events of the topics having a Starlark script.")
+	fs.StringVar(&o.Source, "script.source", o.Source, "Where the scripts are loaded from, file or etcd, which reloads them when their key changes.")
+	fs.StringVar(&o.Dir, "script.dir", o.Dir, "Directory of the {topic}.star script files.")
+	fs.IntVar(&o.Timeout, "script.timeout", o.Timeout, "Milliseconds a script may run for an event before it is dropped.")
+}
@@ -0,0 +1,49 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SpoolOptions defines the local spool messages are written to before the
+// output.
+type SpoolOptions struct {
+	Enabled     bool   `json:"enabled" mapstructure:"enabled"`
+	Dir         string `json:"dir" mapstructure:"dir"`
+	MaxSize     int    `json:"max-size" mapstructure:"max-size"`
+	SegmentSize int    `json:"segment-size" mapstructure:"segment-size"`
+	MaxInFlight int    `json:"max-in-flight" mapstructure:"max-in-flight"`
+}
+
+func NewSpoolOptions() *SpoolOptions {
+	return &SpoolOptions{
+		Dir:         "data/spool",
+		MaxSize:     10240,
+		SegmentSize: 64,
+		MaxInFlight: 1000,
+	}
+}
+
+func (o *SpoolOptions) Validate() []error {
+	errs := []error{}
+	if !o.Enabled {
+		return errs
+	}
+	if o.Dir == "" {
+		errs = append(errs, fmt.Errorf("spool dir can not be empty"))
+	}
+	if o.MaxSize <= 0 || o.SegmentSize <= 0 || o.MaxInFlight <= 0 {
+		errs = append(errs, fmt.Errorf("spool max-size, segment-size and max-in-flight must be positive"))
+	}
+
+	return errs
+}
+
+func (o *SpoolOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "output.spool.enabled", o.Enabled, "Write messages to a local spool and finish them before publishing to the output.")
+	fs.StringVar(&o.Dir, "output.spool.dir", o.Dir, "Directory of the spool segments.")
+	fs.IntVar(&o.MaxSize, "output.spool.max-size", o.MaxSize, "Max size of the spool in megabytes, messages are requeued on nsq when it is full.")
+	fs.IntVar(&o.SegmentSize, "output.spool.segment-size", o.SegmentSize, "Size in megabytes after which a new spool segment is started.")
+	fs.IntVar(&o.MaxInFlight, "output.spool.max-in-flight", o.MaxInFlight, "Max number of replayed messages the output has not acknowledged.")
+}
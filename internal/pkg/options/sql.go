@@ -0,0 +1,71 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SQLTableOptions maps the events of a topic to the columns of a table.
+type SQLTableOptions struct {
+	Table   string            `json:"table" mapstructure:"table"`
+	Columns map[string]string `json:"columns" mapstructure:"columns"`
+	Keys    []string          `json:"keys" mapstructure:"keys"`
+}
+
+// SQLOptions defines options for the mysql and postgresql output.
+type SQLOptions struct {
+	Driver       string                      `json:"driver" mapstructure:"driver"`
+	DSN          string                      `json:"dsn" mapstructure:"dsn"`
+	Upsert       bool                        `json:"upsert" mapstructure:"upsert"`
+	BatchSize    int                         `json:"batch-size" mapstructure:"batch-size"`
+	MaxOpenConns int                         `json:"max-open-conns" mapstructure:"max-open-conns"`
+	Topics       map[string]*SQLTableOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewSQLOptions() *SQLOptions {
+	return &SQLOptions{
+		Driver:       "mysql",
+		BatchSize:    100,
+		MaxOpenConns: 4,
+		Topics:       map[string]*SQLTableOptions{},
+	}
+}
+
+func (o *SQLOptions) Validate() []error {
+	errs := []error{}
+	switch o.Driver {
+	case "mysql", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported sql driver %q", o.Driver))
+	}
+	if o.DSN == "" {
+		errs = append(errs, fmt.Errorf("sql dsn can not be empty"))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("sql batch-size must be greater than 0"))
+	}
+	for topic, t := range o.Topics {
+		if t.Table == "" || len(t.Columns) == 0 {
+			errs = append(errs, fmt.Errorf("sql table and columns of topic %s can not be empty", topic))
+		}
+		if o.Upsert && len(t.Keys) == 0 {
+			errs = append(errs, fmt.Errorf("sql keys of topic %s are required by upsert", topic))
+		}
+		for _, key := range t.Keys {
+			if _, ok := t.Columns[key]; !ok {
+				errs = append(errs, fmt.Errorf("sql key %s of topic %s is not a column", key, topic))
+			}
+		}
+	}
+
+	return errs
+}
+
+func (o *SQLOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Driver, "sql.driver", o.Driver, "database/sql driver name, one of mysql, postgres.")
+	fs.StringVar(&o.DSN, "sql.dsn", o.DSN, "Data source name passed to the driver.")
+	fs.BoolVar(&o.Upsert, "sql.upsert", o.Upsert, "Update rows conflicting on the table keys instead of failing.")
+	fs.IntVar(&o.BatchSize, "sql.batch-size", o.BatchSize, "Max number of rows per transaction.")
+	fs.IntVar(&o.MaxOpenConns, "sql.max-open-conns", o.MaxOpenConns, "Max number of open database connections.")
+}
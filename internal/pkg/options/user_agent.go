@@ -0,0 +1,100 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// UserAgentTopicOptions defines which field of the events of a topic holds
+// the user agent to parse.
+type UserAgentTopicOptions struct {
+	Field  string `json:"field" mapstructure:"field"`
+	Target string `json:"target" mapstructure:"target"`
+}
+
+func (o *UserAgentTopicOptions) Validate(name string) []error {
+	errs := []error{}
+	if o.Field != "" && o.Target == "" {
+		errs = append(errs, fmt.Errorf("user-agent target of %s can not be empty", name))
+	}
+
+	return errs
+}
+
+// UserAgentOptions defines how the user agents of the topics are parsed, the
+// top level field and target apply to topics without their own.
+type UserAgentOptions struct {
+	UserAgentTopicOptions `json:",inline" mapstructure:",squash"`
+
+	Regexes        string `json:"regexes" mapstructure:"regexes"`
+	CacheSize      int    `json:"cache-size" mapstructure:"cache-size"`
+	ReloadInterval int    `json:"reload-interval" mapstructure:"reload-interval"`
+
+	Topics map[string]*UserAgentTopicOptions `json:"topics" mapstructure:"topics"`
+}
+
+func NewUserAgentOptions() *UserAgentOptions {
+	return &UserAgentOptions{
+		UserAgentTopicOptions: UserAgentTopicOptions{
+			Target: "user_agent",
+		},
+		CacheSize:      10000,
+		ReloadInterval: 60,
+		Topics:         map[string]*UserAgentTopicOptions{},
+	}
+}
+
+func (o *UserAgentOptions) Validate() []error {
+	errs := o.UserAgentTopicOptions.Validate("user-agent")
+	for topic := range o.Topics {
+		errs = append(errs, o.TopicOptions(topic).Validate(topic)...)
+	}
+	if o.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("user-agent cache-size must not be negative"))
+	}
+	if o.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("user-agent reload-interval must be greater than 0"))
+	}
+
+	return errs
+}
+
+// TopicOptions returns the options of topic, with unset settings taken from
+// the defaults.
+func (o *UserAgentOptions) TopicOptions(topic string) *UserAgentTopicOptions {
+	t, ok := o.Topics[topic]
+	if !ok {
+		return &o.UserAgentTopicOptions
+	}
+
+	merged := *t
+	if merged.Field == "" {
+		merged.Field = o.Field
+	}
+	if merged.Target == "" {
+		merged.Target = o.Target
+	}
+	return &merged
+}
+
+// Enabled reports whether any topic parses user agents.
+func (o *UserAgentOptions) Enabled() bool {
+	if o.Field != "" {
+		return true
+	}
+	for _, t := range o.Topics {
+		if t.Field != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *UserAgentOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Field, "user-agent.field", o.Field, "Dotted event field holding the user agent, empty disables the parsing.")
+	fs.StringVar(&o.Target, "user-agent.target", o.Target, "Dotted event field the browser, os and device are added to.")
+	fs.StringVar(&o.Regexes, "user-agent.regexes", o.Regexes, "regexes.yaml file of the ua-parser project, the built-in regexes are used when empty.")
+	fs.IntVar(&o.CacheSize, "user-agent.cache-size", o.CacheSize, "Number of user agent parses cached.")
+	fs.IntVar(&o.ReloadInterval, "user-agent.reload-interval", o.ReloadInterval, "Seconds between the checks of the regexes file, which is reloaded when it changes.")
+}